@@ -2,45 +2,114 @@ package cairn
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/wirehaiku/cairn/vm"
 )
 
 // Cairn is a complete program environment.
 type Cairn struct {
-	Queue  *Queue
-	Stack  *Stack
-	Table  *Table
-	Funcs  map[string]CairnFunc
-	Input  io.Reader
-	Output io.Writer
+	Queue   *Queue
+	Stack   *Stack
+	Table   *Table
+	Dict    *Dict
+	Input   *bufio.Reader
+	Output  io.Writer
+	Machine *vm.Machine
+	Model   Model
+	Symbols *vm.Symbols
+	Caps    Caps
+	EOF     int
+	Timeout time.Duration
+	cache   []*Word
+	cached  *Dict
+	version int
 }
 
 // CairnFunc is a Cairn program function.
 type CairnFunc func(*Cairn) error
 
-// NewCairn returns a pointer to a new Cairn.
+// NewCairn returns a pointer to a new Cairn, buffering its input Reader.
 func NewCairn(r io.Reader, w io.Writer) *Cairn {
-	return &Cairn{NewQueue(), NewStack(), NewTable(nil), Funcs, r, w}
+	c := &Cairn{
+		Queue:   NewQueue(),
+		Stack:   NewStack(),
+		Table:   NewTable(nil),
+		Dict:    NewDict(Funcs),
+		Input:   bufio.NewReader(r),
+		Output:  w,
+		Model:   DefaultModel,
+		Symbols: vm.NewSymbols(),
+		Caps:    AllCaps,
+	}
+
+	c.Machine = vm.NewMachine(c)
+	return c
+}
+
+// Allow returns a PermissionError if the Cairn does not allow a capability.
+func (c *Cairn) Allow(cs Caps) error {
+	if !c.Caps.Has(cs) {
+		return &PermissionError{cs}
+	}
+
+	return nil
+}
+
+// Call calls a function in the Cairn by symbol ID, running a user-defined function
+// on the Cairn's Machine.
+func (c *Cairn) Call(id int) error {
+	w, err := c.word(id)
+	if err != nil {
+		return err
+	}
+
+	if w.Program != nil {
+		return c.Machine.Run(w.Program)
+	}
+
+	return w.Func(c)
+}
+
+// Compile returns a compiled Program from a parsed Block.
+func (c *Cairn) Compile(b Block) (*vm.Program, error) {
+	p := new(vm.Program)
+	if err := c.compile(p, b); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Define sets a user-defined function in the Cairn's Dict by symbol ID.
+func (c *Cairn) Define(id int, p *vm.Program) {
+	c.Dict.Set(c.Symbols.Name(id), Word{nil, p})
 }
 
 // Evaluate evaluates an atom against the Cairn.
 func (c *Cairn) Evaluate(a any) error {
 	switch a := a.(type) {
 	case int:
-		c.Stack.Push(a)
-		return nil
+		return c.Push(a)
 
 	case string:
-		f, err := c.GetFunc(a)
+		return c.Call(c.Symbols.ID(a))
+
+	case CairnFunc:
+		return a(c)
+
+	case Atom, Block, *Cond, *Def, *Help, *Loop, *Test:
+		p, err := c.Compile(Block{a})
 		if err != nil {
 			return err
 		}
 
-		return f(c)
-
-	case CairnFunc:
-		return a(c)
+		return c.Machine.Run(p)
 
 	default:
 		return fmt.Errorf(`cannot evaluate atom type "%T"`, a)
@@ -60,58 +129,165 @@ func (c *Cairn) EvaluateAll(as []any) error {
 
 // Execute parses and enqueues a program string and evaluates it against the Cairn.
 func (c *Cairn) Execute(s string) error {
-	ss := Tokenise(s)
-	as := AtomiseAll(ss)
-	c.Queue.EnqueueAll(as)
+	return c.ExecuteFile("", s)
+}
 
-	for !c.Queue.Empty() {
-		a, err := c.Queue.Dequeue()
-		if err != nil {
-			return err
-		}
+// ExecuteContext parses and enqueues a named program string and evaluates it against
+// the Cairn with a fresh instruction count and Timeout, stopping when a Context is done.
+func (c *Cairn) ExecuteContext(ctx context.Context, f, s string) error {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
 
-		if err := c.Evaluate(a); err != nil {
-			return err
-		}
+	old := c.Machine.Context
+	c.Machine.Context, c.Machine.Count = ctx, 0
+	defer func() { c.Machine.Context = old }()
+	return c.execute(f, s)
+}
+
+// ExecuteFile parses and enqueues a named program string and evaluates it against the
+// Cairn with a fresh instruction count and Timeout.
+func (c *Cairn) ExecuteFile(f, s string) error {
+	ctx := c.Machine.Context
+	if ctx == nil {
+		ctx = context.Background()
 	}
 
-	return nil
+	return c.ExecuteContext(ctx, f, s)
 }
 
 // GetFunc returns a CairnFunc from the Cairn.
 func (c *Cairn) GetFunc(s string) (CairnFunc, error) {
-	f, ok := c.Funcs[s]
+	w, ok := c.Dict.Get(s)
 	if !ok {
 		return nil, fmt.Errorf("function %q does not exist", s)
 	}
 
-	return f, nil
+	if w.Program != nil {
+		return func(c *Cairn) error {
+			return c.Machine.Run(w.Program)
+		}, nil
+	}
+
+	return w.Func, nil
 }
 
-// Read returns a rune from the Cairn's input Reader.
-func (c *Cairn) Read() rune {
-	bs := make([]byte, 1)
-	c.Input.Read(bs)
-	return rune(bs[0])
+// Get returns the value of a register in the Cairn's Table.
+func (c *Cairn) Get(r int) (int, error) {
+	if err := c.register(r); err != nil {
+		return 0, err
+	}
+
+	return c.Table.Get(r), nil
 }
 
-// ReadString returns a string from the Cairn's input Reader.
-func (c *Cairn) ReadString(r rune) string {
-	b := bufio.NewReader(c.Input)
-	s, _ := b.ReadString(byte(r))
-	return s
+// Help writes the documentation of a function in the Cairn by symbol ID, from the
+// comment of a user-defined function or the Docs of a builtin function.
+func (c *Cairn) Help(id int) error {
+	if err := c.Allow(CapOutput); err != nil {
+		return err
+	}
+
+	s := c.Symbols.Name(id)
+	w, ok := c.Dict.Get(s)
+	if !ok {
+		return fmt.Errorf("function %q does not exist", s)
+	}
+
+	ds := builtinDocs()
+	i, ok := slices.BinarySearchFunc(ds, s, func(d Doc, s string) int { return strings.Compare(d.Name, s) })
+
+	d := Doc{Name: s}
+	switch {
+	case w.Program != nil:
+		d = ParseDoc(s, w.Program.Doc)
+	case ok && !c.Dict.Has(s):
+		d = ds[i]
+	}
+
+	c.WriteString("%s\n", strings.Join(strings.Fields(strings.ToUpper(d.Name)+" "+d.Effect+" "+d.Text), " "))
+	return nil
+}
+
+// Pop removes and returns the top integer on the Cairn's Stack.
+func (c *Cairn) Pop() (int, error) {
+	return c.Stack.Pop()
 }
 
-// SetFunc sets a CairnFunc in the Cairn.
+// Push appends an integer fitted to the Cairn's Model to the top of the Cairn's Stack.
+func (c *Cairn) Push(i int) error {
+	i, err := c.Model.Fit(i)
+	if err != nil {
+		return err
+	}
+
+	if c.Model.StackCap != 0 && c.Stack.Len() >= c.Model.StackCap {
+		return &vm.LimitError{Err: vm.ErrStack, Max: c.Model.StackCap}
+	}
+
+	c.Stack.Push(i)
+	return nil
+}
+
+// Read returns a byte from the Cairn's input Reader as a rune, or io.EOF at the end
+// of input.
+func (c *Cairn) Read() (rune, error) {
+	b, err := c.Input.ReadByte()
+	return rune(b), err
+}
+
+// ReadString returns a string up to and including a delimiter from the Cairn's input
+// Reader, or the string read so far and io.EOF at the end of input.
+func (c *Cairn) ReadString(r rune) (string, error) {
+	return c.Input.ReadString(byte(r))
+}
+
+// Set sets the value of a register in the Cairn's Table, fitted to the Cairn's Model.
+func (c *Cairn) Set(r, v int) error {
+	if err := c.register(r); err != nil {
+		return err
+	}
+
+	v, err := c.Model.Fit(v)
+	if err != nil {
+		return err
+	}
+
+	c.Table.Set(r, v)
+	return nil
+}
+
+// SetFunc sets a CairnFunc in the Cairn's Dict.
 func (c *Cairn) SetFunc(s string, f CairnFunc) {
-	c.Funcs[s] = f
+	c.Dict.Set(s, Word{f, nil})
 }
 
-// SetFuncAtoms seta a CairnFunc in the Cairn from an atom slice.
-func (c *Cairn) SetFuncAtoms(s string, as []any) {
-	c.Funcs[s] = func(c *Cairn) error {
-		return c.EvaluateAll(as)
+// SetFuncBlock sets a user-defined function in the Cairn from a parsed Block.
+func (c *Cairn) SetFuncBlock(s string, b Block) error {
+	p, err := c.Compile(b)
+	if err != nil {
+		return err
 	}
+
+	c.Define(c.Symbols.ID(s), p)
+	return nil
+}
+
+// User returns a user-defined function in the Cairn by symbol ID, or calls the
+// builtin function if none exists.
+func (c *Cairn) User(id int) (*vm.Program, error) {
+	w, err := c.word(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if w.Program != nil {
+		return w.Program, nil
+	}
+
+	return nil, w.Func(c)
 }
 
 // Write writes a rune to the Cairn's output Writer.
@@ -123,3 +299,65 @@ func (c *Cairn) Write(r rune) {
 func (c *Cairn) WriteString(s string, vs ...any) {
 	fmt.Fprintf(c.Output, s, vs...)
 }
+
+// execute parses and enqueues a named program string and evaluates it against the Cairn.
+func (c *Cairn) execute(f, s string) error {
+	b, err := ParseString(f, s, c.Model)
+	if err != nil {
+		return err
+	}
+
+	c.Queue.EnqueueAll(b)
+
+	for !c.Queue.Empty() {
+		a, err := c.Queue.Dequeue()
+		if err != nil {
+			return err
+		}
+
+		if err := c.Evaluate(a); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// register returns an error if a register is outside the Cairn's Table.
+func (c *Cairn) register(r int) error {
+	if r < 0 {
+		return fmt.Errorf("register %d does not exist", r)
+	}
+
+	if c.Model.TableCap != 0 && r >= c.Model.TableCap {
+		return &vm.LimitError{Err: vm.ErrTable, Max: c.Model.TableCap}
+	}
+
+	return nil
+}
+
+// word returns a cached Word from the Cairn's Dict by symbol ID, clearing the
+// cache whenever the Dict has changed.
+func (c *Cairn) word(id int) (*Word, error) {
+	if c.cached != c.Dict || c.version != c.Dict.Version {
+		c.cache = nil
+		c.cached = c.Dict
+		c.version = c.Dict.Version
+	}
+
+	for len(c.cache) < c.Symbols.Len() {
+		c.cache = append(c.cache, nil)
+	}
+
+	if c.cache[id] == nil {
+		s := c.Symbols.Name(id)
+		w, ok := c.Dict.Get(s)
+		if !ok {
+			return nil, fmt.Errorf("function %q does not exist", s)
+		}
+
+		c.cache[id] = &w
+	}
+
+	return c.cache[id], nil
+}
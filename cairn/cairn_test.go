@@ -1,10 +1,17 @@
 package cairn
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"errors"
+	"io"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/wirehaiku/cairn/vm"
 )
 
 func xCairn(s string) (*Cairn, *bytes.Buffer) {
@@ -19,9 +26,85 @@ func TestNewCairn(t *testing.T) {
 	assert.NotNil(t, c.Queue)
 	assert.NotNil(t, c.Stack)
 	assert.NotNil(t, c.Table)
-	assert.Equal(t, Funcs, c.Funcs)
+	assert.Len(t, c.Dict.Base, len(Funcs))
+	assert.Empty(t, c.Dict.Words)
 	assert.NotNil(t, c.Input)
 	assert.NotNil(t, c.Output)
+	assert.Equal(t, c, c.Machine.Env)
+	assert.Equal(t, DefaultModel, c.Model)
+	assert.NotNil(t, c.Symbols)
+	assert.Equal(t, AllCaps, c.Caps)
+	assert.Zero(t, c.EOF)
+}
+
+func TestCairnIsolation(t *testing.T) {
+	// setup
+	c1, _ := xCairn("")
+	c2, _ := xCairn("")
+
+	// success
+	err := c1.Execute("def iso 1 end")
+	assert.True(t, c1.Dict.Has("iso"))
+	assert.False(t, c2.Dict.Has("iso"))
+	assert.NoError(t, err)
+
+	// success - cache follows dict changes
+	c1.Dict.Reset()
+	err = c1.Execute("iso")
+	assert.EqualError(t, err, `1:1: function "iso" does not exist`)
+}
+
+func TestCairnAllow(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Caps = CapInput | CapOutput
+
+	// success
+	err := c.Allow(CapInput | CapOutput)
+	assert.NoError(t, err)
+
+	// failure - not allowed
+	err = c.Allow(CapExit)
+	assert.Equal(t, &PermissionError{CapExit}, err)
+}
+
+func TestCairnCall(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{1, 2})
+
+	// success - builtin function
+	err := c.Call(c.Symbols.ID("+"))
+	assert.Equal(t, []int{3}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// success - user function
+	c.SetFuncBlock("TEST", Block{1, "+"})
+	err = c.Call(c.Symbols.ID("TEST"))
+	assert.Equal(t, []int{4}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// failure - function does not exist
+	err = c.Call(c.Symbols.ID("NOPE"))
+	assert.EqualError(t, err, `function "NOPE" does not exist`)
+
+	// failure - recursive redefined builtin
+	err = c.Execute("def dup dup end 1 dup")
+	assert.ErrorIs(t, err, vm.ErrDepth)
+	assert.ErrorContains(t, err, "call depth limit of 10000 exceeded")
+}
+
+func TestCairnDefine(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	p, _ := c.Compile(Block{123})
+
+	// success
+	c.Define(c.Symbols.ID("TEST"), p)
+	err := c.Evaluate("TEST")
+	assert.True(t, c.Dict.Has("TEST"))
+	assert.Equal(t, []int{123}, c.Stack.Integers)
+	assert.NoError(t, err)
 }
 
 func TestCairnEvaluate(t *testing.T) {
@@ -47,6 +130,37 @@ func TestCairnEvaluate(t *testing.T) {
 	assert.Equal(t, []int{3}, c.Stack.Integers)
 	assert.NoError(t, err)
 
+	// setup
+	c.Stack.Integers = []int{1}
+
+	// success - block
+	err = c.Evaluate(Block{2, "+"})
+	assert.Equal(t, []int{3}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// success - true condition
+	c.Stack.Integers = []int{1}
+	err = c.Evaluate(&Cond{Want: true, Body: Block{123}})
+	assert.Equal(t, []int{123}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// success - false condition
+	c.Stack.Integers = []int{1}
+	err = c.Evaluate(&Cond{Want: false, Body: Block{123}})
+	assert.Empty(t, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// success - definition
+	err = c.Evaluate(&Def{Name: "foo", Body: Block{123}})
+	assert.True(t, c.Dict.Has("foo"))
+	assert.NoError(t, err)
+
+	// success - loop
+	c.Stack.Clear()
+	err = c.Evaluate(&Loop{Reg: 0, Body: Block{123}})
+	assert.Equal(t, []int{123}, c.Stack.Integers)
+	assert.NoError(t, err)
+
 	// failure - invalid type
 	err = c.Evaluate(false)
 	assert.EqualError(t, err, `cannot evaluate atom type "bool"`)
@@ -70,6 +184,99 @@ func TestCairnExecute(t *testing.T) {
 	err := c.Execute("1 2 +")
 	assert.Equal(t, []int{3}, c.Stack.Integers)
 	assert.NoError(t, err)
+
+	// success - nested control flow in definition
+	c.Stack.Clear()
+	err = c.Execute("def foo 1 ift 2 ift 3 end end end foo 4")
+	assert.Equal(t, []int{3, 4}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// failure - missing end
+	err = c.Execute("1 ift 2")
+	assert.EqualError(t, err, `1:3: missing "end"`)
+}
+
+func TestCairnExecuteExit(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+
+	// success - exit unwinds evaluation
+	err := c.Execute("def f 3 die 4 end 1 f 2")
+	assert.Equal(t, []int{1}, c.Stack.Integers)
+
+	var e *ExitError
+	assert.ErrorAs(t, err, &e)
+	assert.Equal(t, 3, e.Code)
+}
+
+func TestCairnExecuteContext(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	// success
+	err := c.ExecuteContext(ctx, "a", "1 2 +")
+	assert.Equal(t, []int{3}, c.Stack.Integers)
+	assert.Nil(t, c.Machine.Context)
+	assert.NoError(t, err)
+
+	// success - fresh instruction count
+	c.Stack.Clear()
+	c.Machine.Limits = vm.Limits{Instrs: 5}
+	for range 3 {
+		err = c.ExecuteContext(context.Background(), "a", "1 2 + drop")
+		assert.NoError(t, err)
+	}
+
+	// failure - context done
+	c.Machine.Limits = vm.Limits{}
+	err = c.ExecuteContext(ctx, "a", "1 0 set for 0 end")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// failure - instruction limit
+	c.Machine.Limits = vm.Limits{Instrs: 1000}
+	err = c.ExecuteContext(context.Background(), "a", "1 0 set for 0 end")
+	assert.ErrorIs(t, err, vm.ErrInstrs)
+
+	// failure - timeout
+	c.Queue.Clear()
+	c.Machine.Limits = vm.Limits{}
+	c.Timeout = time.Millisecond
+	err = c.ExecuteContext(context.Background(), "a", "1 0 set for 0 end")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, c.Machine.Context)
+
+	// success - fresh timeout
+	err = c.ExecuteContext(context.Background(), "a", "1 2 +")
+	assert.NoError(t, err)
+	c.Timeout = 0
+
+	// failure - call depth limit
+	c.Queue.Clear()
+	c.Machine.Limits = vm.Limits{Depth: 100}
+	err = c.ExecuteContext(context.Background(), "a", "def f f end f")
+	assert.ErrorIs(t, err, vm.ErrDepth)
+	assert.EqualError(t, err, "a:1:7: call depth limit of 100 exceeded")
+}
+
+func TestCairnExecuteFile(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+
+	// success
+	err := c.ExecuteFile("a.txt", "1 2 +")
+	assert.Equal(t, []int{3}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// failure - positioned error with trace
+	c.Stack.Clear()
+	err = c.ExecuteFile("a.txt", "def foo\n\tclr +\nend\n1 foo")
+	assert.Equal(t, &vm.Error{
+		Err:   errors.New("stack is empty"),
+		Pos:   vm.Pos{File: "a.txt", Line: 2, Col: 6},
+		Trace: []vm.Site{{Name: "foo", Pos: vm.Pos{File: "a.txt", Line: 4, Col: 3}}},
+	}, err)
 }
 
 func TestCairnGetFunc(t *testing.T) {
@@ -87,22 +294,178 @@ func TestCairnGetFunc(t *testing.T) {
 	assert.EqualError(t, err, `function "NOPE" does not exist`)
 }
 
+func TestCairnGet(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Table.Set(0, 123)
+
+	// success
+	i, err := c.Get(0)
+	assert.Equal(t, 123, i)
+	assert.NoError(t, err)
+
+	// failure - register does not exist
+	_, err = c.Get(-1)
+	assert.EqualError(t, err, "register -1 does not exist")
+
+	// failure - table limit
+	_, err = c.Get(8)
+	assert.ErrorIs(t, err, vm.ErrTable)
+	assert.EqualError(t, err, "table limit of 8 exceeded")
+}
+
+func TestCairnHelp(t *testing.T) {
+	// setup
+	c, b := xCairn("")
+	err := c.Execute("def sq // (a -- b) squares a.\n dup * end 0 ift def gone // never.\n end end")
+	assert.NoError(t, err)
+
+	// success - builtin function
+	err = c.Help(c.Symbols.ID("dup"))
+	assert.Equal(t, "DUP (a -- a a) Duplicates the top integer.\n", b.String())
+	assert.NoError(t, err)
+
+	// success - defined function
+	b.Reset()
+	err = c.Help(c.Symbols.ID("sq"))
+	assert.Equal(t, "SQ (a -- b) Squares a.\n", b.String())
+	assert.NoError(t, err)
+
+	// success - undocumented function
+	b.Reset()
+	c.SetFunc("dup", LogicNoOpFunc)
+	err = c.Help(c.Symbols.ID("dup"))
+	assert.Equal(t, "DUP\n", b.String())
+	assert.NoError(t, err)
+
+	// success - inside def and ift
+	b.Reset()
+	err = c.Execute("def h help sq end 5 h 1 ift help sq end 7")
+	assert.Equal(t, "SQ (a -- b) Squares a.\nSQ (a -- b) Squares a.\n", b.String())
+	assert.Equal(t, []int{5, 7}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// success - inside eva
+	b.Reset()
+	c.Stack.PushAll([]int{10, 'q', 's', ' ', 'p', 'l', 'e', 'h'})
+	err = SystemEvalFunc(c)
+	assert.Equal(t, "SQ (a -- b) Squares a.\n", b.String())
+	assert.Equal(t, []int{5, 7}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// failure - function never defined
+	err = c.Help(c.Symbols.ID("gone"))
+	assert.EqualError(t, err, `function "gone" does not exist`)
+
+	// failure - function forgotten
+	c.Dict.Reset()
+	err = c.Help(c.Symbols.ID("sq"))
+	assert.EqualError(t, err, `function "sq" does not exist`)
+
+	// failure - output not allowed
+	c.Caps = 0
+	err = c.Help(c.Symbols.ID("dup"))
+	assert.ErrorAs(t, err, new(*PermissionError))
+}
+
+func TestCairnPop(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.Push(123)
+
+	// success
+	i, err := c.Pop()
+	assert.Equal(t, 123, i)
+	assert.NoError(t, err)
+}
+
+func TestCairnPush(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+
+	// success
+	err := c.Push(123)
+	assert.Equal(t, []int{123}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// success - wrapped integer
+	err = c.Push(257)
+	assert.Equal(t, []int{123, 1}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// failure - trapped integer
+	c.Model.Trap = true
+	err = c.Push(256)
+	assert.EqualError(t, err, "integer 256 overflows unsigned 8-bit word")
+
+	// failure - stack limit
+	c.Model.StackCap = 2
+	err = c.Push(1)
+	assert.ErrorIs(t, err, vm.ErrStack)
+	assert.EqualError(t, err, "stack limit of 2 exceeded")
+}
+
 func TestCairnRead(t *testing.T) {
 	// setup
-	c, _ := xCairn("test\n")
+	c, _ := xCairn("ab")
 
 	// success
-	r := c.Read()
-	assert.Equal(t, 't', r)
+	r, err := c.Read()
+	assert.Equal(t, 'a', r)
+	assert.NoError(t, err)
+
+	// success - buffered input
+	r, err = c.Read()
+	assert.Equal(t, 'b', r)
+	assert.NoError(t, err)
+
+	// failure - end of input
+	_, err = c.Read()
+	assert.Equal(t, io.EOF, err)
+
+	// failure - input error
+	c.Input = bufio.NewReader(iotest.ErrReader(errors.New("test")))
+	_, err = c.Read()
+	assert.EqualError(t, err, "test")
 }
 
 func TestCairnReadString(t *testing.T) {
 	// setup
-	c, _ := xCairn("test\n")
+	c, _ := xCairn("test\nrest")
 
 	// success
-	s := c.ReadString('\n')
+	s, err := c.ReadString('\n')
 	assert.Equal(t, "test\n", s)
+	assert.NoError(t, err)
+
+	// success - shared input
+	r, err := c.Read()
+	assert.Equal(t, 'r', r)
+	assert.NoError(t, err)
+
+	// failure - end of input
+	s, err = c.ReadString('\n')
+	assert.Equal(t, "est", s)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestCairnSet(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+
+	// success
+	err := c.Set(0, 256)
+	assert.Equal(t, 0, c.Table.Get(0))
+	assert.NoError(t, err)
+
+	// failure - register does not exist
+	err = c.Set(-1, 1)
+	assert.EqualError(t, err, "register -1 does not exist")
+
+	// failure - table limit
+	err = c.Set(8, 1)
+	assert.ErrorIs(t, err, vm.ErrTable)
+	assert.EqualError(t, err, "table limit of 8 exceeded")
 }
 
 func TestCairnSetFunc(t *testing.T) {
@@ -111,17 +474,37 @@ func TestCairnSetFunc(t *testing.T) {
 
 	// success
 	c.SetFunc("TEST", MathAddFunc)
-	assert.NotNil(t, c.Funcs["TEST"])
+	assert.True(t, c.Dict.Has("TEST"))
 }
 
-func TestCairnSetFuncAtoms(t *testing.T) {
+func TestCairnSetFuncBlock(t *testing.T) {
 	// setup
 	c, _ := xCairn("")
 
 	// success
-	c.SetFuncAtoms("TEST", []any{1, 2, "+"})
-	err := c.Evaluate("TEST")
-	assert.NotNil(t, c.Funcs["TEST"])
+	err := c.SetFuncBlock("TEST", Block{1, 2, "+"})
+	assert.NoError(t, err)
+
+	err = c.Evaluate("TEST")
+	assert.True(t, c.Dict.Has("TEST"))
+	assert.Equal(t, []int{3}, c.Stack.Integers)
+	assert.NoError(t, err)
+}
+
+func TestCairnUser(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{1, 2})
+	c.SetFuncBlock("TEST", Block{123})
+
+	// success - user function
+	p, err := c.User(c.Symbols.ID("TEST"))
+	assert.Equal(t, []vm.Instr{{Op: vm.Push, Arg: 123}}, p.Code)
+	assert.NoError(t, err)
+
+	// success - builtin function
+	p, err = c.User(c.Symbols.ID("+"))
+	assert.Nil(t, p)
 	assert.Equal(t, []int{3}, c.Stack.Integers)
 	assert.NoError(t, err)
 }
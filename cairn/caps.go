@@ -0,0 +1,97 @@
+package cairn
+
+import (
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+)
+
+// Caps is a set of capabilities a Cairn allows its builtin functions to use.
+type Caps uint8
+
+// Capabilities for exiting the program, reading input, writing output, using the
+// filesystem, reading the clock and generating random numbers.
+const (
+	CapExit Caps = 1 << iota
+	CapInput
+	CapOutput
+	CapFS
+	CapClock
+	CapRand
+)
+
+// AllCaps is the set of all capabilities.
+const AllCaps = CapExit | CapInput | CapOutput | CapFS | CapClock | CapRand
+
+// ExitError is an error that stops a Cairn program with an exit code.
+type ExitError struct {
+	Code int
+}
+
+// PermissionError is an error for a builtin function using a disallowed capability.
+type PermissionError struct {
+	Cap Caps
+}
+
+// capNames is the name of each capability in bit order.
+var capNames = []string{"exit", "input", "output", "fs", "clock", "rand"}
+
+// ParseCaps returns a Caps from a comma-separated list of capability names, "all"
+// or "none".
+func ParseCaps(s string) (Caps, error) {
+	var cs Caps
+	for _, s := range strings.Split(s, ",") {
+		switch s = strings.TrimSpace(strings.ToLower(s)); s {
+		case "all":
+			cs |= AllCaps
+		case "none", "":
+			continue
+		default:
+			i := slices.Index(capNames, s)
+			if i < 0 {
+				return 0, fmt.Errorf("capability %q does not exist", s)
+			}
+
+			cs |= 1 << i
+		}
+	}
+
+	return cs, nil
+}
+
+// Has returns true if the Caps contains all capabilities in another Caps.
+func (cs Caps) Has(c Caps) bool {
+	return cs&c == c
+}
+
+// String returns the Caps as a comma-separated list of capability names.
+func (cs Caps) String() string {
+	var ss []string
+	for i, s := range capNames {
+		if cs.Has(1 << i) {
+			ss = append(ss, s)
+		}
+	}
+
+	if len(ss) == 0 {
+		return "none"
+	}
+
+	return strings.Join(ss, ",")
+}
+
+// Error returns the ExitError as a string.
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// Error returns the PermissionError as a string.
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("%s capability is not allowed", e.Cap)
+}
+
+// Unwrap returns fs.ErrPermission.
+func (e *PermissionError) Unwrap() error {
+	return fs.ErrPermission
+}
@@ -0,0 +1,60 @@
+package cairn
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCaps(t *testing.T) {
+	// success - names
+	cs, err := ParseCaps("exit, INPUT")
+	assert.Equal(t, CapExit|CapInput, cs)
+	assert.NoError(t, err)
+
+	// success - all and none
+	cs, err = ParseCaps("all")
+	assert.Equal(t, AllCaps, cs)
+	assert.NoError(t, err)
+
+	cs, err = ParseCaps("none")
+	assert.Zero(t, cs)
+	assert.NoError(t, err)
+
+	// failure - invalid name
+	cs, err = ParseCaps("exit,nope")
+	assert.Zero(t, cs)
+	assert.EqualError(t, err, `capability "nope" does not exist`)
+}
+
+func TestCapsHas(t *testing.T) {
+	// success
+	assert.True(t, AllCaps.Has(CapExit|CapRand))
+	assert.False(t, CapExit.Has(CapExit|CapRand))
+}
+
+func TestCapsString(t *testing.T) {
+	// success
+	assert.Equal(t, "exit,input,output,fs,clock,rand", AllCaps.String())
+	assert.Equal(t, "output", CapOutput.String())
+	assert.Equal(t, "none", Caps(0).String())
+}
+
+func TestExitErrorError(t *testing.T) {
+	// success
+	e := &ExitError{123}
+	assert.Equal(t, "exit status 123", e.Error())
+}
+
+func TestPermissionErrorError(t *testing.T) {
+	// success
+	e := &PermissionError{CapFS}
+	assert.Equal(t, "fs capability is not allowed", e.Error())
+}
+
+func TestPermissionErrorUnwrap(t *testing.T) {
+	// success
+	e := &PermissionError{CapFS}
+	assert.Equal(t, fs.ErrPermission, e.Unwrap())
+}
@@ -0,0 +1,177 @@
+package cairn
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/wirehaiku/cairn/vm"
+)
+
+// Checker is a static analyser that infers the stack effects of parsed programs and
+// collects the Problems it finds.
+type Checker struct {
+	Effects  map[string]Effect
+	Problems []Problem
+}
+
+// Problem is a problem found by static analysis, with a source position and a kind.
+type Problem struct {
+	Pos     vm.Pos
+	Kind    string
+	Message string
+}
+
+// state is the inferred height of a stack relative to its starting height, with the
+// lowest height reached and unknown true if the height cannot be inferred.
+type state struct {
+	h       int
+	lo      int
+	unknown bool
+}
+
+// checkPasses is the most passes a Checker makes to infer the effects of functions
+// called before their definitions.
+const checkPasses = 16
+
+// NewChecker returns a pointer to a new Checker using the builtin Effects.
+func NewChecker() *Checker {
+	return &Checker{Effects: maps.Clone(Effects)}
+}
+
+// CheckFile parses a named program string against a Model and checks it, starting
+// with an empty stack and repeating until the effects of functions called before
+// their definitions are known, or returns an error if it does not parse.
+func (ch *Checker) CheckFile(f, s string, m Model) error {
+	b, err := ParseString(f, s, m)
+	if err != nil {
+		return err
+	}
+
+	for i, n := 0, len(ch.Problems); i < checkPasses; i++ {
+		es := maps.Clone(ch.Effects)
+		ch.block(b, &state{}, true)
+		ch.Problems = ch.Problems[:n]
+		if maps.Equal(es, ch.Effects) {
+			break
+		}
+	}
+
+	ch.block(b, &state{}, true)
+	return nil
+}
+
+// String returns the Problem as a string.
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Pos, p.Message)
+}
+
+// apply applies an Effect to a state, reporting a guaranteed underflow if top is
+// true and the state's height is known.
+func (ch *Checker) apply(st *state, e Effect, name string, pos vm.Pos, top bool) {
+	if st.unknown {
+		return
+	}
+
+	if top && st.h < e.In {
+		ch.report(pos, "underflow", "stack underflow: %q needs %d integers but the stack has %d",
+			name, e.In, st.h)
+		st.unknown = true
+		return
+	}
+
+	st.h -= e.In
+	st.lo = min(st.lo, st.h)
+	st.h += e.Out
+	st.unknown = e.Var
+}
+
+// atom checks a parsed atom or node against a state.
+func (ch *Checker) atom(a any, pos vm.Pos, st *state, top bool) {
+	switch a := a.(type) {
+	case Atom:
+		ch.atom(a.Value, a.Pos, st, top)
+
+	case int:
+		ch.apply(st, Effect{0, 1, false}, fmt.Sprint(a), pos, top)
+
+	case Quote:
+		ch.apply(st, Effect{0, len(a) + 1, false}, string(a), pos, top)
+
+	case string:
+		e, ok := ch.Effects[a]
+		if !ok {
+			st.unknown = true
+			return
+		}
+
+		ch.apply(st, e, a, pos, top)
+
+	case *Cond:
+		ch.apply(st, Effect{1, 0, false}, node(a), a.Pos, top)
+		e, ok := ch.body(a.Body, node(a), a.Pos)
+		switch {
+		case !ok:
+			st.unknown = true
+		case top && st.h < e.In:
+			st.unknown = true
+		default:
+			ch.apply(st, e, node(a), a.Pos, false)
+		}
+
+	case *Def:
+		ch.Effects[a.Name] = Effect{0, 0, true}
+		bs := &state{}
+		ch.block(a.Body, bs, false)
+
+		e := Effect{-bs.lo, bs.h - bs.lo, bs.unknown}
+		ch.Effects[a.Name] = e
+
+		ce, ok := ParseEffect(a.Doc)
+		if ok && !ce.Var && !e.Var && (ce.Net() != e.Net() || e.In > ce.In) {
+			ch.report(a.Pos, "effect", "function %q has effect %s but its comment says %s",
+				a.Name, e, ce)
+		}
+
+	case *Loop:
+		e, ok := ch.body(a.Body, "for", a.Pos)
+		if !ok {
+			st.unknown = true
+			return
+		}
+
+		ch.apply(st, e, "for", a.Pos, top)
+
+	case *Test:
+		ch.apply(st, Effect{1, 0, false}, "tst", a.Pos, top)
+	}
+}
+
+// block checks a Block against a state.
+func (ch *Checker) block(b Block, st *state, top bool) {
+	for _, a := range b {
+		ch.atom(a, vm.Pos{}, st, top)
+	}
+}
+
+// body returns the Effect of a branch or loop body and true if it is known and
+// balanced, reporting an unbalanced body.
+func (ch *Checker) body(b Block, name string, pos vm.Pos) (Effect, bool) {
+	st := &state{}
+	ch.block(b, st, false)
+	if st.unknown {
+		return Effect{}, false
+	}
+
+	e := Effect{-st.lo, st.h - st.lo, false}
+	if e.Net() != 0 {
+		ch.report(pos, "branch", "%s body has unbalanced effect %s", name, e)
+		return Effect{}, false
+	}
+
+	return e, true
+}
+
+// report adds a Problem to the Checker.
+func (ch *Checker) report(pos vm.Pos, kind, s string, vs ...any) {
+	ch.Problems = append(ch.Problems, Problem{pos, kind, fmt.Sprintf(s, vs...)})
+}
@@ -0,0 +1,94 @@
+package cairn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wirehaiku/cairn/vm"
+)
+
+func xCheck(s string) []string {
+	ch := NewChecker()
+	ch.CheckFile("library", Library, DefaultModel)
+	ch.CheckFile("", s, DefaultModel)
+
+	var ss []string
+	for _, p := range ch.Problems {
+		ss = append(ss, p.Kind+" "+p.String())
+	}
+
+	return ss
+}
+
+func TestNewChecker(t *testing.T) {
+	// success
+	ch := NewChecker()
+	assert.Equal(t, Effects, ch.Effects)
+	assert.Empty(t, ch.Problems)
+}
+
+func TestCheckerCheckFile(t *testing.T) {
+	// setup
+	ch := NewChecker()
+
+	// success - library
+	err := ch.CheckFile("library", Library, DefaultModel)
+	assert.Equal(t, Effect{2, 1, false}, ch.Effects["!="])
+	assert.Equal(t, Effect{1, 1, false}, ch.Effects["t?"])
+	assert.Empty(t, ch.Problems)
+	assert.NoError(t, err)
+
+	// success - inferred effects
+	ss := xCheck("def sq dup * end def two 1 2 end 3 sq two + +")
+	assert.Empty(t, ss)
+
+	// success - effect mismatch
+	ss = xCheck("def sq // (a -- b c)\n dup * end")
+	assert.Equal(t, []string{
+		`effect 1:1: function "sq" has effect (a -- b) but its comment says (a -- b c)`,
+	}, ss)
+
+	// success - underflow
+	ss = xCheck("1 add")
+	assert.Equal(t, []string{
+		`underflow 1:3: stack underflow: "add" needs 2 integers but the stack has 1`,
+	}, ss)
+
+	ss = xCheck("def sq dup * end\nsq")
+	assert.Equal(t, []string{
+		`underflow 2:1: stack underflow: "sq" needs 1 integers but the stack has 0`,
+	}, ss)
+
+	// success - unbalanced branch
+	ss = xCheck("1 ift 2 end 0 for 0 1 2 end")
+	assert.Equal(t, []string{
+		`branch 1:3: ift body has unbalanced effect (-- a)`,
+		`branch 1:15: for body has unbalanced effect (-- a b)`,
+	}, ss)
+
+	// success - no underflow in a branch
+	ss = xCheck("1 iff drop 0 end")
+	assert.Empty(t, ss)
+
+	// success - no underflow after a variable effect
+	ss = xCheck("0 1 2 print drop nope drop")
+	assert.Empty(t, ss)
+
+	// success - no underflow after pick and roll
+	ss = xCheck("1 2 1 pick 1 roll drop drop drop")
+	assert.Empty(t, ss)
+
+	// success - no effect for help
+	ss = xCheck("def sq dup * end help sq")
+	assert.Empty(t, ss)
+
+	// failure - parse error
+	err = ch.CheckFile("", "def", DefaultModel)
+	assert.Error(t, err)
+}
+
+func TestProblemString(t *testing.T) {
+	// success
+	s := Problem{vm.Pos{File: "a", Line: 1, Col: 2}, "underflow", "msg"}.String()
+	assert.Equal(t, "a:1:2: msg", s)
+}
@@ -0,0 +1,99 @@
+package cairn
+
+import (
+	"fmt"
+
+	"github.com/wirehaiku/cairn/vm"
+)
+
+// compile appends the compiled instructions for a parsed Block to a Program.
+func (c *Cairn) compile(p *vm.Program, b Block) error {
+	for _, a := range b {
+		if err := c.compileAtom(p, a, vm.Pos{}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compileAtom appends the compiled instructions for an atom or node to a Program.
+func (c *Cairn) compileAtom(p *vm.Program, a any, pos vm.Pos) error {
+	switch a := a.(type) {
+	case int:
+		p.Emit(pos, vm.Push, a, 0)
+
+	case string:
+		p.Emit(pos, c.compileCall(a), c.Symbols.ID(a), 0)
+
+	case Atom:
+		return c.compileAtom(p, a.Value, a.Pos)
+
+	case Quote:
+		for _, b := range []byte(a) {
+			p.Emit(pos, vm.Push, int(b), 0)
+		}
+
+		p.Emit(pos, vm.Push, len(a), 0)
+
+	case Block:
+		return c.compile(p, a)
+
+	case *Cond:
+		op := vm.BranchFalse
+		if !a.Want {
+			op = vm.BranchTrue
+		}
+
+		i := p.Emit(a.Pos, op, 0, 0)
+		if err := c.compile(p, a.Body); err != nil {
+			return err
+		}
+
+		p.Patch(i, len(p.Code))
+
+	case *Def:
+		sp, err := c.Compile(a.Body)
+		if err != nil {
+			return err
+		}
+
+		sp.Name, sp.Doc = a.Name, a.Doc
+		p.Subs = append(p.Subs, sp)
+		p.Emit(a.Pos, vm.Define, c.Symbols.ID(a.Name), len(p.Subs)-1)
+
+	case *Help:
+		p.Emit(a.Pos, vm.Help, c.Symbols.ID(a.Name), 0)
+
+	case *Loop:
+		if err := c.register(a.Reg); err != nil {
+			return &vm.Error{Err: err, Pos: a.Pos}
+		}
+
+		i := len(p.Code)
+		if err := c.compile(p, a.Body); err != nil {
+			return err
+		}
+
+		p.Emit(a.Pos, vm.Loop, i, a.Reg)
+
+	case *Test:
+		p.Texts = append(p.Texts, a.Text)
+		p.Emit(a.Pos, vm.Assert, len(p.Texts)-1, 0)
+
+	default:
+		return &vm.Error{Err: fmt.Errorf(`cannot compile atom type "%T"`, a), Pos: pos}
+	}
+
+	return nil
+}
+
+// compileCall returns the call operation for a symbol: Go functions are called
+// directly, everything else is called as a user function.
+func (c *Cairn) compileCall(s string) vm.Op {
+	if w, ok := c.Dict.Get(s); ok && w.Program == nil {
+		return vm.Call
+	}
+
+	return vm.User
+}
@@ -0,0 +1,66 @@
+package cairn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wirehaiku/cairn/vm"
+)
+
+func TestCairnCompile(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	b, _ := ParseString("", "1 + qux ift 2 end iff 3 end for 0 4 end def bar // baz\n5 end", DefaultModel)
+
+	// success
+	p, err := c.Compile(b)
+	assert.Equal(t, []vm.Instr{
+		{Op: vm.Push, Arg: 1},
+		{Op: vm.Call, Arg: c.Symbols.ID("+")},
+		{Op: vm.User, Arg: c.Symbols.ID("qux")},
+		{Op: vm.BranchFalse, Arg: 5},
+		{Op: vm.Push, Arg: 2},
+		{Op: vm.BranchTrue, Arg: 7},
+		{Op: vm.Push, Arg: 3},
+		{Op: vm.Push, Arg: 4},
+		{Op: vm.Loop, Arg: 7, Aux: 0},
+		{Op: vm.Define, Arg: c.Symbols.ID("bar"), Aux: 0},
+	}, p.Code)
+	assert.Equal(t, []vm.Instr{{Op: vm.Push, Arg: 5}}, p.Subs[0].Code)
+	assert.Equal(t, "bar", p.Subs[0].Name)
+	assert.Equal(t, "baz", p.Subs[0].Doc)
+	assert.NoError(t, err)
+
+	// success - test
+	b, _ = ParseString("", "tst 1 end", DefaultModel)
+	p, err = c.Compile(b)
+	assert.Equal(t, []vm.Instr{{Op: vm.Assert, Arg: 0}}, p.Code)
+	assert.Equal(t, []string{"1"}, p.Texts)
+	assert.NoError(t, err)
+
+	// success - help
+	b, _ = ParseString("", "help dup", DefaultModel)
+	p, err = c.Compile(b)
+	assert.Equal(t, []vm.Instr{{Op: vm.Help, Arg: c.Symbols.ID("dup")}}, p.Code)
+	assert.NoError(t, err)
+
+	// success - string literal
+	b, _ = ParseString("", `"hi"`, DefaultModel)
+	p, err = c.Compile(b)
+	assert.Equal(t, []vm.Instr{
+		{Op: vm.Push, Arg: 'h'}, {Op: vm.Push, Arg: 'i'}, {Op: vm.Push, Arg: 2},
+	}, p.Code)
+	assert.NoError(t, err)
+
+	// failure - loop register outside table
+	b, _ = ParseString("", "for 8 end", DefaultModel)
+	p, err = c.Compile(b)
+	assert.Nil(t, p)
+	assert.ErrorIs(t, err, vm.ErrTable)
+	assert.EqualError(t, err, "1:1: table limit of 8 exceeded")
+
+	// failure - invalid type
+	p, err = c.Compile(Block{false})
+	assert.Nil(t, p)
+	assert.EqualError(t, err, `cannot compile atom type "bool"`)
+}
@@ -0,0 +1,263 @@
+package cairn
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/wirehaiku/cairn/vm"
+)
+
+// Debugger is an interactive debugger that pauses a Cairn's Machine at breakpoints
+// and single steps, reading commands from a prompt.
+type Debugger struct {
+	Cairn   *Cairn
+	Input   *bufio.Reader
+	Output  io.Writer
+	Symbols map[string]bool
+	Lines   map[int]bool
+	Sources map[string]string
+	mode    int
+	depth   int
+	prevs   []int
+}
+
+// Debugger modes, deciding when the Debugger next pauses.
+const (
+	debugRun = iota
+	debugStep
+	debugNext
+	debugOut
+)
+
+// debugHelp is the Debugger's command summary.
+const debugHelp = `Commands:
+  break SYMBOL|LINE   pause before calling a symbol or entering a line
+  delete SYMBOL|LINE  remove a breakpoint
+  step                pause at the next instruction, stepping into functions
+  next                pause at the next instruction, stepping over functions
+  out                 pause after returning from the current function
+  continue            pause at the next breakpoint
+  info                show the stack, registers and queue
+  where               show the chain of function calls
+  quit                stop the program
+`
+
+// errQuit is returned by a Debugger's hook to stop the program.
+var errQuit = errors.New("debugger quit")
+
+// NewDebugger returns a pointer to a new Debugger for a Cairn, paused before the
+// first instruction and reading commands through the Cairn's Input if given it.
+func NewDebugger(c *Cairn, r io.Reader, w io.Writer) *Debugger {
+	return &Debugger{
+		Cairn:   c,
+		Input:   bufio.NewReader(r),
+		Output:  w,
+		Symbols: make(map[string]bool),
+		Lines:   make(map[int]bool),
+		Sources: make(map[string]string),
+		mode:    debugStep,
+	}
+}
+
+// After does nothing, satisfying vm.Hook.
+func (d *Debugger) After(f *vm.Frame, in vm.Instr) error {
+	return nil
+}
+
+// Before pauses the Debugger's Cairn before an instruction if a breakpoint or step
+// matches it.
+func (d *Debugger) Before(f *vm.Frame, in vm.Instr) error {
+	pos, depth := f.Pos(), len(d.Cairn.Machine.Frames)
+	prev := d.prev(pos.Line, depth)
+
+	if !d.pause(pos, in, depth, prev) {
+		return nil
+	}
+
+	fmt.Fprintf(d.Output, "%s: %s\n", pos, describe(d.Cairn.Symbols, in))
+	fmt.Fprint(d.Output, snippet(pos, d.Sources))
+	d.info()
+
+	for {
+		fmt.Fprint(d.Output, "(debug) ")
+		s, err := d.Input.ReadString('\n')
+		if err != nil && s == "" {
+			return errQuit
+		}
+
+		ss := strings.Fields(strings.ToLower(s))
+		if len(ss) == 0 {
+			continue
+		}
+
+		ok, err := d.command(ss, depth)
+		switch {
+		case errors.Is(err, errQuit):
+			return err
+		case err != nil:
+			fmt.Fprintf(d.Output, "Error: %s.\n", err)
+		case ok:
+			return nil
+		}
+	}
+}
+
+// ExecuteFile parses and evaluates a named program string against the Debugger's
+// Cairn, pausing for commands.
+func (d *Debugger) ExecuteFile(f, s string) error {
+	m := d.Cairn.Machine
+	n := len(m.Hooks)
+	d.Sources[f] = s
+	m.Hooks = append(m.Hooks, d)
+	defer func() { m.Hooks = m.Hooks[:n] }()
+
+	err := d.Cairn.ExecuteFile(f, s)
+	if errors.Is(err, errQuit) {
+		d.Cairn.Queue.Clear()
+		return nil
+	}
+
+	return err
+}
+
+// command runs a debugger command and returns true if the program should resume.
+func (d *Debugger) command(ss []string, depth int) (bool, error) {
+	switch ss[0] {
+	case "b", "break", "d", "delete":
+		if len(ss) < 2 {
+			return false, fmt.Errorf("command %q needs a symbol or line", ss[0])
+		}
+
+		on := ss[0] == "b" || ss[0] == "break"
+		for _, s := range ss[1:] {
+			if l, err := strconv.Atoi(s); err == nil {
+				d.Lines[l] = on
+			} else {
+				d.Symbols[strings.ToLower(s)] = on
+			}
+		}
+
+		return false, nil
+
+	case "s", "step":
+		d.mode = debugStep
+		return true, nil
+
+	case "n", "next":
+		d.mode, d.depth = debugNext, depth
+		return true, nil
+
+	case "o", "out":
+		d.mode, d.depth = debugOut, depth
+		return true, nil
+
+	case "c", "continue":
+		d.mode = debugRun
+		return true, nil
+
+	case "i", "info":
+		d.info()
+		return false, nil
+
+	case "w", "where":
+		for _, s := range d.Cairn.Machine.Trace() {
+			fmt.Fprintf(d.Output, "  in %q called at %s\n", s.Name, s.Pos)
+		}
+
+		return false, nil
+
+	case "q", "quit":
+		return false, errQuit
+
+	case "h", "help":
+		fmt.Fprint(d.Output, debugHelp)
+		return false, nil
+
+	default:
+		return false, fmt.Errorf("unknown command %q", ss[0])
+	}
+}
+
+// info writes the Cairn's Stack, Table registers and remaining Queue to the
+// Debugger's output.
+func (d *Debugger) info() {
+	var qs []string
+	for _, a := range d.Cairn.Queue.Atoms {
+		qs = append(qs, node(a))
+	}
+
+	fmt.Fprintf(d.Output, "  stack: [ %s ]\n", d.Cairn.Stack.String())
+	fmt.Fprintf(d.Output, "  table: [ %s ]\n", d.Cairn.Table.String())
+	fmt.Fprintf(d.Output, "  queue: [ %s ]\n", strings.Join(qs, " "))
+}
+
+// pause returns true if the Debugger should pause before an instruction.
+func (d *Debugger) pause(pos vm.Pos, in vm.Instr, depth, prev int) bool {
+	switch {
+	case d.mode == debugStep:
+		return true
+	case d.mode == debugNext && depth <= d.depth:
+		return true
+	case d.mode == debugOut && depth < d.depth:
+		return true
+	case (in.Op == vm.Call || in.Op == vm.User) && d.Symbols[d.Cairn.Symbols.Name(in.Arg)]:
+		return true
+	default:
+		return d.Lines[pos.Line] && pos.Line != prev
+	}
+}
+
+// prev records the line of an instruction at a call depth and returns the line of
+// the previous instruction at that depth, forgetting any deeper calls.
+func (d *Debugger) prev(l, depth int) int {
+	d.prevs = d.prevs[:min(len(d.prevs), depth)]
+	for len(d.prevs) < depth {
+		d.prevs = append(d.prevs, 0)
+	}
+
+	prev := d.prevs[depth-1]
+	d.prevs[depth-1] = l
+	return prev
+}
+
+// describe returns an instruction as a string, with symbol names in place of IDs.
+func describe(ss *vm.Symbols, in vm.Instr) string {
+	switch in.Op {
+	case vm.Call, vm.User, vm.Define, vm.Help:
+		return fmt.Sprintf("%s %s", in.Op, ss.Name(in.Arg))
+	case vm.Assert:
+		return in.Op.String()
+	default:
+		return fmt.Sprintf("%s %d", in.Op, in.Arg)
+	}
+}
+
+// node returns a short description of a parsed atom or node.
+func node(a any) string {
+	switch a := a.(type) {
+	case Atom:
+		return node(a.Value)
+	case Quote:
+		return strconv.Quote(string(a))
+	case *Cond:
+		if a.Want {
+			return "ift"
+		}
+
+		return "iff"
+	case *Def:
+		return "def " + a.Name
+	case *Help:
+		return "help " + a.Name
+	case *Loop:
+		return fmt.Sprintf("for %d", a.Reg)
+	case *Test:
+		return "tst"
+	default:
+		return fmt.Sprintf("%v", a)
+	}
+}
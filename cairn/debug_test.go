@@ -0,0 +1,126 @@
+package cairn
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wirehaiku/cairn/vm"
+)
+
+func xDebugger(s string) (*Debugger, *bytes.Buffer) {
+	c, _ := xCairn("")
+	b := bytes.NewBuffer(nil)
+	return NewDebugger(c, strings.NewReader(s), b), b
+}
+
+func TestNewDebugger(t *testing.T) {
+	// success
+	d, b := xDebugger("")
+	assert.NotNil(t, d.Cairn)
+	assert.NotNil(t, d.Input)
+	assert.Equal(t, b, d.Output)
+	assert.Empty(t, d.Symbols)
+	assert.Empty(t, d.Lines)
+	assert.Empty(t, d.Sources)
+	assert.Equal(t, debugStep, d.mode)
+
+	// success - shared input
+	d = NewDebugger(d.Cairn, d.Cairn.Input, b)
+	assert.Same(t, d.Cairn.Input, d.Input)
+}
+
+func TestDebuggerExecuteFile(t *testing.T) {
+	// success - step
+	d, b := xDebugger("s\ns\nc\n")
+	err := d.ExecuteFile("a", "1 2 3")
+	assert.Equal(t, []int{1, 2, 3}, d.Cairn.Stack.Integers)
+	assert.True(t, strings.HasPrefix(b.String(), "a:1:1: push 1\n"+
+		" 1 | 1 2 3\n"+
+		"   | ^\n"+
+		"  stack: [  ]\n"+
+		"  table: [  ]\n"+
+		"  queue: [ 2 3 ]\n"+
+		"(debug) a:1:3: push 2\n"))
+	assert.Contains(t, b.String(), "a:1:5: push 3\n")
+	assert.Empty(t, d.Cairn.Machine.Hooks)
+	assert.NoError(t, err)
+
+	// success - break on symbol
+	d, b = xDebugger("b foo\nc\ni\nc\n")
+	err = d.ExecuteFile("a", "def foo 2 end\n1 0 set\nfoo")
+	assert.Contains(t, b.String(), "a:3:1: user foo\n")
+	assert.Contains(t, b.String(), "  stack: [  ]\n  table: [ 0:1 ]\n")
+	assert.NoError(t, err)
+
+	// success - break on line and step over
+	d, b = xDebugger("b 3\nc\nn\nc\n")
+	err = d.ExecuteFile("a", "def foo 2 end\n\nfoo 3")
+	assert.Contains(t, b.String(), "a:3:1: user foo\n")
+	assert.Contains(t, b.String(), "a:3:5: push 3\n")
+	assert.NotContains(t, b.String(), "a:1:9: push 2\n")
+	assert.NoError(t, err)
+
+	// success - break on line once after returning
+	d, b = xDebugger("b 2\nc\nc\n")
+	err = d.ExecuteFile("a", "def foo 2 end\nfoo 3")
+	assert.Contains(t, b.String(), "a:2:1: user foo\n")
+	assert.NotContains(t, b.String(), "a:2:5: push 3\n")
+	assert.NoError(t, err)
+
+	// success - step into and out
+	d, b = xDebugger("b foo\nc\ns\no\nc\n")
+	err = d.ExecuteFile("a", "def foo 2 end\nfoo 3")
+	assert.Contains(t, b.String(), "a:1:9: push 2\n")
+	assert.Contains(t, b.String(), "a:2:5: push 3\n")
+	assert.NoError(t, err)
+
+	// success - quit
+	d, b = xDebugger("q\n")
+	err = d.ExecuteFile("a", "1 2 3")
+	assert.Empty(t, d.Cairn.Stack.Integers)
+	assert.Empty(t, d.Cairn.Queue.Atoms)
+	assert.NoError(t, err)
+
+	// success - quit on end of input
+	d, b = xDebugger("")
+	err = d.ExecuteFile("a", "1")
+	assert.Empty(t, d.Cairn.Stack.Integers)
+	assert.NoError(t, err)
+
+	// success - unknown command
+	d, b = xDebugger("nope\nc\n")
+	err = d.ExecuteFile("a", "1")
+	assert.Contains(t, b.String(), "Error: unknown command \"nope\".\n")
+	assert.NoError(t, err)
+
+	// failure - program error
+	d, b = xDebugger("c\n")
+	err = d.ExecuteFile("a", "nope")
+	assert.EqualError(t, err, `a:1:1: function "nope" does not exist`)
+}
+
+func TestDescribe(t *testing.T) {
+	// setup
+	ss := vm.NewSymbols()
+	id := ss.ID("foo")
+
+	// success
+	assert.Equal(t, "push 1", describe(ss, vm.Instr{Op: vm.Push, Arg: 1}))
+	assert.Equal(t, "user foo", describe(ss, vm.Instr{Op: vm.User, Arg: id}))
+	assert.Equal(t, "assert", describe(ss, vm.Instr{Op: vm.Assert}))
+	assert.Equal(t, "help foo", describe(ss, vm.Instr{Op: vm.Help, Arg: id}))
+}
+
+func TestNode(t *testing.T) {
+	// success
+	assert.Equal(t, "123", node(Atom{123, vm.Pos{}}))
+	assert.Equal(t, `"ab"`, node(Quote("ab")))
+	assert.Equal(t, "ift", node(&Cond{Want: true}))
+	assert.Equal(t, "iff", node(&Cond{Want: false}))
+	assert.Equal(t, "def foo", node(&Def{Name: "foo"}))
+	assert.Equal(t, "help foo", node(&Help{Name: "foo"}))
+	assert.Equal(t, "for 1", node(&Loop{Reg: 1}))
+	assert.Equal(t, "tst", node(&Test{}))
+}
@@ -0,0 +1,56 @@
+package cairn
+
+import (
+	"strings"
+
+	"github.com/wirehaiku/cairn/vm"
+)
+
+// Definition is a function definition found in a program string, with the comment
+// following its name and the positions of its "def", name and "end" tokens.
+type Definition struct {
+	Name    string
+	Comment string
+	Pos     vm.Pos
+	NamePos vm.Pos
+	EndPos  vm.Pos
+}
+
+// Definitions returns the function definitions in a named program string in source
+// order, including those in program strings that do not parse.
+func Definitions(f, s string) []Definition {
+	var ds []Definition
+	var open []int
+	ts := TokeniseComments(f, s)
+	w := blockWalker{ts: ts}
+
+	for b, ok := w.next(); ok; b, ok = w.next() {
+		switch {
+		case b.key == "def":
+			if b.operand < 0 {
+				return ds
+			}
+
+			n := ts[b.operand]
+			d := Definition{Name: strings.ToLower(n.Text), Pos: ts[b.index].Pos, NamePos: n.Pos}
+			if j := b.operand + 1; j < len(ts) && strings.HasPrefix(ts[j].Text, "//") {
+				d.Comment = strings.TrimSpace(strings.TrimPrefix(ts[j].Text, "//"))
+			}
+
+			open = append(open, len(ds))
+			ds = append(ds, d)
+
+		case b.depth > 0:
+			open = append(open, -1)
+
+		case b.depth < 0 && len(open) != 0:
+			if j := open[len(open)-1]; j >= 0 {
+				ds[j].EndPos = ts[b.index].Pos
+			}
+
+			open = open[:len(open)-1]
+		}
+	}
+
+	return ds
+}
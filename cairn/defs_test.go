@@ -0,0 +1,38 @@
+package cairn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wirehaiku/cairn/vm"
+)
+
+func TestDefinitions(t *testing.T) {
+	// setup
+	s := "def Foo // (a -- b) Do foo.\n\tift 1 end\nend\ndef bar end\ndef baz"
+
+	// success
+	ds := Definitions("a", s)
+	assert.Equal(t, []Definition{
+		{"foo", "(a -- b) Do foo.", vm.Pos{File: "a", Line: 1, Col: 1},
+			vm.Pos{File: "a", Line: 1, Col: 5}, vm.Pos{File: "a", Line: 3, Col: 1}},
+		{"bar", "", vm.Pos{File: "a", Line: 4, Col: 1},
+			vm.Pos{File: "a", Line: 4, Col: 5}, vm.Pos{File: "a", Line: 4, Col: 9}},
+		{"baz", "", vm.Pos{File: "a", Line: 5, Col: 1},
+			vm.Pos{File: "a", Line: 5, Col: 5}, vm.Pos{}},
+	}, ds)
+
+	// success - comment before name
+	ds = Definitions("", "def // c\nfoo 1 end")
+	assert.Equal(t, []Definition{
+		{"foo", "", vm.Pos{Line: 1, Col: 1}, vm.Pos{Line: 2, Col: 1}, vm.Pos{Line: 2, Col: 7}},
+	}, ds)
+
+	// success - unmatched end and missing name
+	ds = Definitions("", "end def")
+	assert.Empty(t, ds)
+
+	// success - help names
+	ds = Definitions("", "ift help end end help def")
+	assert.Empty(t, ds)
+}
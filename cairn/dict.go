@@ -0,0 +1,101 @@
+package cairn
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/wirehaiku/cairn/vm"
+)
+
+// Dict is a copy-on-write dictionary of user-defined functions layered over an
+// immutable map of builtin functions.
+type Dict struct {
+	Base    map[string]CairnFunc
+	Words   map[string]Word
+	Version int
+	shared  bool
+}
+
+// Word is a user-defined function, either a Go function or a compiled Program.
+type Word struct {
+	Func    CairnFunc
+	Program *vm.Program
+}
+
+// NewDict returns a pointer to a new Dict over a copy of a builtin function map.
+func NewDict(fm map[string]CairnFunc) *Dict {
+	return &Dict{maps.Clone(fm), make(map[string]Word), 0, false}
+}
+
+// Fork returns a new Dict sharing the Dict's current words until either is changed.
+func (d *Dict) Fork() *Dict {
+	d.shared = true
+	return &Dict{d.Base, d.Words, 0, true}
+}
+
+// Get returns a function from the Dict, preferring user-defined words over builtins.
+func (d *Dict) Get(s string) (Word, bool) {
+	if w, ok := d.Words[s]; ok {
+		return w, true
+	}
+
+	if f, ok := d.Base[s]; ok {
+		return Word{f, nil}, true
+	}
+
+	return Word{}, false
+}
+
+// Has returns true if the Dict contains a user-defined word.
+func (d *Dict) Has(s string) bool {
+	_, ok := d.Words[s]
+	return ok
+}
+
+// Names returns the sorted names of all functions in the Dict.
+func (d *Dict) Names() []string {
+	var ss []string
+	for s := range d.Base {
+		ss = append(ss, s)
+	}
+
+	for s := range d.Words {
+		if _, ok := d.Base[s]; !ok {
+			ss = append(ss, s)
+		}
+	}
+
+	slices.Sort(ss)
+	return ss
+}
+
+// Reset removes all user-defined words from the Dict.
+func (d *Dict) Reset() {
+	d.Words = make(map[string]Word)
+	d.Version++
+	d.shared = false
+}
+
+// Restore replaces the Dict's words with those of a snapshot Dict.
+func (d *Dict) Restore(sd *Dict) {
+	sd.shared = true
+	d.Words = sd.Words
+	d.Version++
+	d.shared = true
+}
+
+// Set sets a user-defined word in the Dict, copying shared words first.
+func (d *Dict) Set(s string, w Word) {
+	if d.shared {
+		d.Words = maps.Clone(d.Words)
+		d.shared = false
+	}
+
+	d.Words[s] = w
+	d.Version++
+}
+
+// Snapshot returns a copy of the Dict that can be restored later with Restore.
+func (d *Dict) Snapshot() *Dict {
+	return d.Fork()
+}
@@ -0,0 +1,128 @@
+package cairn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func xDict() *Dict {
+	d := NewDict(map[string]CairnFunc{"+": MathAddFunc})
+	d.Set("foo", Word{LogicNoOpFunc, nil})
+	return d
+}
+
+func TestNewDict(t *testing.T) {
+	// success
+	d := NewDict(Funcs)
+	assert.Len(t, d.Base, len(Funcs))
+	assert.Empty(t, d.Words)
+	assert.Zero(t, d.Version)
+
+	// success - copied builtins
+	fm := map[string]CairnFunc{"nop": LogicNoOpFunc}
+	d = NewDict(fm)
+	fm["new"] = LogicNoOpFunc
+	_, ok := d.Get("new")
+	assert.False(t, ok)
+}
+
+func TestDictFork(t *testing.T) {
+	// setup
+	d := xDict()
+
+	// success
+	d2 := d.Fork()
+	assert.True(t, d2.Has("foo"))
+
+	// success - copy on write
+	d2.Set("bar", Word{LogicNoOpFunc, nil})
+	d.Set("baz", Word{LogicNoOpFunc, nil})
+	assert.False(t, d.Has("bar"))
+	assert.False(t, d2.Has("baz"))
+}
+
+func TestDictGet(t *testing.T) {
+	// setup
+	d := xDict()
+
+	// success - user-defined word
+	w, ok := d.Get("foo")
+	assert.NotNil(t, w.Func)
+	assert.True(t, ok)
+
+	// success - builtin function
+	w, ok = d.Get("+")
+	assert.NotNil(t, w.Func)
+	assert.True(t, ok)
+
+	// failure - function does not exist
+	w, ok = d.Get("nope")
+	assert.Nil(t, w.Func)
+	assert.False(t, ok)
+}
+
+func TestDictHas(t *testing.T) {
+	// setup
+	d := xDict()
+
+	// success - true
+	b := d.Has("foo")
+	assert.True(t, b)
+
+	// success - false
+	b = d.Has("+")
+	assert.False(t, b)
+}
+
+func TestDictNames(t *testing.T) {
+	// setup
+	d := xDict()
+
+	// success
+	ss := d.Names()
+	assert.Equal(t, []string{"+", "foo"}, ss)
+}
+
+func TestDictReset(t *testing.T) {
+	// setup
+	d := xDict()
+
+	// success
+	d.Reset()
+	assert.Empty(t, d.Words)
+	assert.Equal(t, 2, d.Version)
+}
+
+func TestDictRestore(t *testing.T) {
+	// setup
+	d := xDict()
+	sd := d.Snapshot()
+	d.Set("bar", Word{LogicNoOpFunc, nil})
+
+	// success
+	d.Restore(sd)
+	assert.True(t, d.Has("foo"))
+	assert.False(t, d.Has("bar"))
+}
+
+func TestDictSet(t *testing.T) {
+	// setup
+	d := xDict()
+
+	// success
+	d.Set("bar", Word{LogicNoOpFunc, nil})
+	assert.True(t, d.Has("bar"))
+	assert.Equal(t, 2, d.Version)
+}
+
+func TestDictSnapshot(t *testing.T) {
+	// setup
+	d := xDict()
+
+	// success
+	sd := d.Snapshot()
+	d.Set("bar", Word{LogicNoOpFunc, nil})
+	assert.True(t, sd.Has("foo"))
+	assert.False(t, sd.Has("bar"))
+}
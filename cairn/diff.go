@@ -0,0 +1,119 @@
+package cairn
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change in a Diff.
+const diffContext = 3
+
+// edit is a line in a diff's edit script, kept, removed or added at a pair of line
+// indexes.
+type edit struct {
+	op   byte
+	text string
+	a, b int
+}
+
+// Diff returns a unified diff from an old to a new string, using names for the old
+// and new files, or an empty string if they are equal.
+func Diff(an, bn, a, b string) string {
+	if a == b {
+		return ""
+	}
+
+	es := script(lines(a), lines(b))
+	var cs []int
+	for i, e := range es {
+		if e.op != ' ' {
+			cs = append(cs, i)
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", an, bn)
+
+	for i := 0; i < len(cs); {
+		j := i
+		for j+1 < len(cs) && cs[j+1]-cs[j]-1 <= 2*diffContext {
+			j++
+		}
+
+		lo, hi := max(cs[i]-diffContext, 0), min(cs[j]+diffContext+1, len(es))
+		var na, nb int
+		for _, e := range es[lo:hi] {
+			if e.op != '+' {
+				na++
+			}
+
+			if e.op != '-' {
+				nb++
+			}
+		}
+
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", span(es[lo].a, na), span(es[lo].b, nb))
+		for _, e := range es[lo:hi] {
+			fmt.Fprintf(&sb, "%c%s\n", e.op, e.text)
+		}
+
+		i = j + 1
+	}
+
+	return sb.String()
+}
+
+// lines returns a string's lines without their line endings.
+func lines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// script returns the shortest edit script from one line slice to another, using
+// their longest common subsequence.
+func script(as, bs []string) []edit {
+	n := make([][]int, len(as)+1)
+	for i := range n {
+		n[i] = make([]int, len(bs)+1)
+	}
+
+	for i := len(as) - 1; i >= 0; i-- {
+		for j := len(bs) - 1; j >= 0; j-- {
+			if as[i] == bs[j] {
+				n[i][j] = n[i+1][j+1] + 1
+			} else {
+				n[i][j] = max(n[i+1][j], n[i][j+1])
+			}
+		}
+	}
+
+	var es []edit
+	i, j := 0, 0
+	for i < len(as) || j < len(bs) {
+		switch {
+		case i < len(as) && j < len(bs) && as[i] == bs[j]:
+			es = append(es, edit{' ', as[i], i, j})
+			i, j = i+1, j+1
+		case j == len(bs) || (i < len(as) && n[i+1][j] >= n[i][j+1]):
+			es = append(es, edit{'-', as[i], i, j})
+			i++
+		default:
+			es = append(es, edit{'+', bs[j], i, j})
+			j++
+		}
+	}
+
+	return es
+}
+
+// span returns a unified diff hunk range from a zero-based line index and count.
+func span(i, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", i)
+	}
+
+	return fmt.Sprintf("%d,%d", i+1, n)
+}
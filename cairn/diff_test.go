@@ -0,0 +1,47 @@
+package cairn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	// success
+	s := Diff("a", "b", "1\n2\n3\n4\n5\n6\n7\n8\n9\n", "1\n2\nx\n4\n5\n6\n7\n8\n9\ny\n")
+	assert.Equal(t, "--- a\n+++ b\n"+
+		"@@ -1,9 +1,10 @@\n"+
+		" 1\n 2\n-3\n+x\n 4\n 5\n 6\n 7\n 8\n 9\n+y\n", s)
+
+	// success - separate hunks
+	s = Diff("a", "b", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", "x\n2\n3\n4\n5\n6\n7\n8\n9\n")
+	assert.Equal(t, "--- a\n+++ b\n"+
+		"@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n"+
+		"@@ -7,4 +7,3 @@\n 7\n 8\n 9\n-10\n", s)
+
+	// success - equal strings
+	s = Diff("a", "b", "1\n", "1\n")
+	assert.Empty(t, s)
+}
+
+func TestLines(t *testing.T) {
+	// success
+	ss := lines("1\n2\n")
+	assert.Equal(t, []string{"1", "2"}, ss)
+
+	// success - empty string
+	ss = lines("")
+	assert.Empty(t, ss)
+}
+
+func TestScript(t *testing.T) {
+	// success
+	es := script([]string{"a", "b"}, []string{"b", "c"})
+	assert.Equal(t, []edit{{'-', "a", 0, 0}, {' ', "b", 1, 0}, {'+', "c", 2, 1}}, es)
+}
+
+func TestSpan(t *testing.T) {
+	// success
+	assert.Equal(t, "3,2", span(2, 2))
+	assert.Equal(t, "2,0", span(2, 0))
+}
@@ -0,0 +1,108 @@
+package cairn
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Doc is the documentation for a function, with its stack effect and description.
+type Doc struct {
+	Name   string
+	Effect string
+	Text   string
+}
+
+// builtinDocs returns the Docs for the builtin functions, parsed on first use.
+var builtinDocs = sync.OnceValue(parseBuiltinDocs)
+
+// BuiltinDocs returns a copy of the Docs for the builtin functions in name order.
+func BuiltinDocs() []Doc {
+	return slices.Clone(builtinDocs())
+}
+
+// ParseDoc returns a Doc for a named function from a comment starting with an
+// optional stack effect, with the description capitalised.
+func ParseDoc(name, s string) Doc {
+	d := Doc{Name: name, Text: strings.TrimSpace(s)}
+	if _, ok := ParseEffect(d.Text); ok && strings.HasPrefix(d.Text, "(") {
+		i := strings.Index(d.Text, ")")
+		d.Effect, d.Text = d.Text[:i+1], strings.TrimSpace(d.Text[i+1:])
+	}
+
+	if r, n := utf8.DecodeRuneInString(d.Text); n > 0 {
+		d.Text = string(unicode.ToUpper(r)) + d.Text[n:]
+	}
+
+	return d
+}
+
+// SourceDocs returns the Docs for the functions defined in a named program string in
+// source order, from the comments following their names.
+func SourceDocs(f, s string) []Doc {
+	var ds []Doc
+	for _, d := range Definitions(f, s) {
+		ds = append(ds, ParseDoc(d.Name, d.Comment))
+	}
+
+	return ds
+}
+
+// WriteDocs writes a titled reference of Docs to a Writer in "text", "markdown" or
+// "html" format.
+func WriteDocs(w io.Writer, title string, ds []Doc, format string) error {
+	var b strings.Builder
+	switch format {
+	case "text":
+		fmt.Fprintf(&b, "%s\n\n", title)
+		for _, d := range ds {
+			fmt.Fprintf(&b, "  %-8s %-18s %s\n", strings.ToUpper(d.Name), d.Effect, d.Text)
+		}
+
+	case "markdown":
+		fmt.Fprintf(&b, "## %s\n\nName | Effect | Description\n---- | ------ | -----------\n", title)
+		for _, d := range ds {
+			fmt.Fprintf(&b, "`%s` | `%s` | %s\n", strings.ToUpper(d.Name), d.Effect, d.Text)
+		}
+
+	case "html":
+		fmt.Fprintf(&b, "<h2>%s</h2>\n<table>\n", html.EscapeString(title))
+		b.WriteString("<tr><th>Name</th><th>Effect</th><th>Description</th></tr>\n")
+		for _, d := range ds {
+			fmt.Fprintf(&b, "<tr><td><code>%s</code></td><td><code>%s</code></td><td>%s</td></tr>\n",
+				html.EscapeString(strings.ToUpper(d.Name)), html.EscapeString(d.Effect),
+				html.EscapeString(d.Text))
+		}
+
+		b.WriteString("</table>\n")
+
+	default:
+		return fmt.Errorf("doc format %q is not text, markdown or html", format)
+	}
+
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// parseBuiltinDocs returns the Docs for the builtin functions in name order, from
+// the doc strings of the Builtins.
+func parseBuiltinDocs() []Doc {
+	var ds []Doc
+	for s, b := range Builtins {
+		d := ParseDoc(s, b.Doc)
+		if d.Effect == "" {
+			d.Effect = b.Effect.String()
+		}
+
+		ds = append(ds, d)
+	}
+
+	slices.SortFunc(ds, func(a, b Doc) int { return strings.Compare(a.Name, b.Name) })
+	return ds
+}
@@ -0,0 +1,79 @@
+package cairn
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuiltinDocs(t *testing.T) {
+	// success
+	ds := BuiltinDocs()
+	assert.Len(t, ds, len(Funcs))
+	assert.Contains(t, ds, Doc{"dup", "(a -- a a)", "Duplicates the top integer."})
+
+	for _, d := range ds {
+		assert.Contains(t, Funcs, d.Name)
+		assert.NotEmpty(t, d.Effect)
+		assert.NotEmpty(t, d.Text)
+	}
+
+	// success - cached copy
+	ds[0].Name = "nope"
+	assert.NotEqual(t, "nope", BuiltinDocs()[0].Name)
+}
+
+func TestParseDoc(t *testing.T) {
+	// success - with effect
+	d := ParseDoc("sq", "(a -- b) squares a.")
+	assert.Equal(t, Doc{"sq", "(a -- b)", "Squares a."}, d)
+
+	// success - without effect
+	d = ParseDoc("sq", " squares a.")
+	assert.Equal(t, Doc{"sq", "", "Squares a."}, d)
+
+	// success - empty comment
+	d = ParseDoc("sq", "")
+	assert.Equal(t, Doc{"sq", "", ""}, d)
+}
+
+func TestSourceDocs(t *testing.T) {
+	// success
+	ds := SourceDocs("", "def SQ // (a -- b) squares a.\n dup * end def one 1 end")
+	assert.Equal(t, []Doc{
+		{"sq", "(a -- b)", "Squares a."},
+		{"one", "", ""},
+	}, ds)
+}
+
+func TestWriteDocs(t *testing.T) {
+	// setup
+	b := new(bytes.Buffer)
+	ds := []Doc{{"lt", "(a b -- c)", "Pushes a < b."}}
+
+	// success - text
+	err := WriteDocs(b, "Title", ds, "text")
+	assert.Equal(t, "Title\n\n  LT       (a b -- c)         Pushes a < b.\n\n", b.String())
+	assert.NoError(t, err)
+
+	// success - markdown
+	b.Reset()
+	err = WriteDocs(b, "Title", ds, "markdown")
+	assert.Equal(t, "## Title\n\nName | Effect | Description\n---- | ------ | -----------\n"+
+		"`LT` | `(a b -- c)` | Pushes a < b.\n\n", b.String())
+	assert.NoError(t, err)
+
+	// success - html
+	b.Reset()
+	err = WriteDocs(b, "Title", ds, "html")
+	assert.Equal(t, "<h2>Title</h2>\n<table>\n"+
+		"<tr><th>Name</th><th>Effect</th><th>Description</th></tr>\n"+
+		"<tr><td><code>LT</code></td><td><code>(a b -- c)</code></td><td>Pushes a &lt; b.</td></tr>\n"+
+		"</table>\n\n", b.String())
+	assert.NoError(t, err)
+
+	// failure - invalid format
+	err = WriteDocs(b, "Title", ds, "nope")
+	assert.EqualError(t, err, `doc format "nope" is not text, markdown or html`)
+}
@@ -0,0 +1,248 @@
+package cairn
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Editor is a line editor with history, reading lines from a buffered Reader and
+// editing them with arrow keys if its Term is a terminal.
+type Editor struct {
+	Input   *bufio.Reader
+	Output  io.Writer
+	History []string
+	File    string
+	Term    *os.File
+}
+
+// HistoryCap is the maximum number of lines an Editor keeps in its history.
+const HistoryCap = 1000
+
+// NewEditor returns a pointer to a new Editor over a buffered Reader and a Writer.
+func NewEditor(r *bufio.Reader, w io.Writer) *Editor {
+	return &Editor{Input: r, Output: w}
+}
+
+// Add adds a line to the Editor's history and appends it to the history File, if
+// the line is not empty or a repeat of the previous line. The File is rewritten
+// with the history once it holds more than HistoryCap lines.
+func (e *Editor) Add(s string) error {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+
+	if n := len(e.History); n > 0 && e.History[n-1] == s {
+		return nil
+	}
+
+	e.History = append(e.History, s)
+	if len(e.History) > HistoryCap {
+		e.History = e.History[len(e.History)-HistoryCap:]
+		return e.save(e.History, false)
+	}
+
+	return e.save([]string{s}, true)
+}
+
+// Load sets the Editor's history File and reads its history from it, ignoring a
+// missing file and trimming a file with more than HistoryCap lines.
+func (e *Editor) Load(name string) error {
+	e.File = name
+	bs, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	e.History = strings.Split(strings.TrimSuffix(string(bs), "\n"), "\n")
+	if len(e.History) > HistoryCap {
+		e.History = e.History[len(e.History)-HistoryCap:]
+		return e.save(e.History, false)
+	}
+
+	return nil
+}
+
+// ReadLine writes a prompt and returns a line without its line ending, editing it
+// in raw mode if the Editor's Term is a terminal.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if e.Term != nil {
+		if restore, err := MakeRaw(e.Term.Fd()); err == nil {
+			defer restore()
+			return e.edit(prompt)
+		}
+	}
+
+	fmt.Fprint(e.Output, prompt)
+	s, err := e.Input.ReadString('\n')
+	if err != nil && s == "" {
+		fmt.Fprint(e.Output, "\n")
+		return "", err
+	}
+
+	return strings.TrimRight(s, "\r\n"), nil
+}
+
+// edit returns a line read key by key from the Editor's input, with arrow keys and
+// control keys for moving, deleting and recalling history.
+func (e *Editor) edit(prompt string) (string, error) {
+	var rs, saved []rune
+	pos, h := 0, len(e.History)
+
+	recall := func(i int) {
+		if h == len(e.History) {
+			saved = rs
+		}
+
+		h = i
+		if h == len(e.History) {
+			rs = saved
+		} else {
+			rs = []rune(e.History[h])
+		}
+
+		pos = len(rs)
+	}
+
+	for {
+		fmt.Fprintf(e.Output, "\r%s%s\x1b[K", prompt, string(rs))
+		if n := len(rs) - pos; n > 0 {
+			fmt.Fprintf(e.Output, "\x1b[%dD", n)
+		}
+
+		r, _, err := e.Input.ReadRune()
+		if err != nil {
+			fmt.Fprint(e.Output, "\n")
+			if len(rs) > 0 {
+				return string(rs), nil
+			}
+
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.Output, "\n")
+			return string(rs), nil
+
+		case 1: // Ctrl-A
+			pos = 0
+
+		case 2: // Ctrl-B
+			pos = max(pos-1, 0)
+
+		case 3: // Ctrl-C
+			fmt.Fprint(e.Output, "^C\n")
+			rs, pos, h = nil, 0, len(e.History)
+
+		case 4: // Ctrl-D
+			if len(rs) == 0 {
+				fmt.Fprint(e.Output, "\n")
+				return "", io.EOF
+			}
+
+			if pos < len(rs) {
+				rs = append(rs[:pos:pos], rs[pos+1:]...)
+			}
+
+		case 5: // Ctrl-E
+			pos = len(rs)
+
+		case 6: // Ctrl-F
+			pos = min(pos+1, len(rs))
+
+		case 8, 127: // Backspace
+			if pos > 0 {
+				rs = append(rs[:pos-1:pos-1], rs[pos:]...)
+				pos--
+			}
+
+		case 11: // Ctrl-K
+			rs = rs[:pos:pos]
+
+		case 21: // Ctrl-U
+			rs, pos = rs[pos:], 0
+
+		case 27: // Escape sequence
+			switch e.escape() {
+			case "[A":
+				if h > 0 {
+					recall(h - 1)
+				}
+			case "[B":
+				if h < len(e.History) {
+					recall(h + 1)
+				}
+			case "[C":
+				pos = min(pos+1, len(rs))
+			case "[D":
+				pos = max(pos-1, 0)
+			case "[H", "[1~", "OH":
+				pos = 0
+			case "[F", "[4~", "OF":
+				pos = len(rs)
+			case "[3~":
+				if pos < len(rs) {
+					rs = append(rs[:pos:pos], rs[pos+1:]...)
+				}
+			}
+
+		default:
+			if r >= ' ' {
+				rs = append(rs[:pos:pos], append([]rune{r}, rs[pos:]...)...)
+				pos++
+			}
+		}
+	}
+}
+
+// escape returns the rest of an escape sequence from the Editor's input, after
+// its escape character.
+func (e *Editor) escape() string {
+	b, err := e.Input.ReadByte()
+	if err != nil || (b != '[' && b != 'O') {
+		return ""
+	}
+
+	s := string(b)
+	for {
+		b, err := e.Input.ReadByte()
+		if err != nil {
+			return s
+		}
+
+		s += string(b)
+		if b >= '@' && b <= '~' {
+			return s
+		}
+	}
+}
+
+// save writes lines to the Editor's history File, appending them or replacing its
+// contents, if the Editor has a File.
+func (e *Editor) save(ss []string, app bool) error {
+	if e.File == "" {
+		return nil
+	}
+
+	f, err := create(e.File, app)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range ss {
+		if _, err2 := fmt.Fprintln(f, s); err == nil {
+			err = err2
+		}
+	}
+
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+
+	return err
+}
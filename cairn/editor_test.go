@@ -0,0 +1,153 @@
+package cairn
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func xEditor(s string) (*Editor, *bytes.Buffer) {
+	b := bytes.NewBuffer(nil)
+	return NewEditor(bufio.NewReader(strings.NewReader(s)), b), b
+}
+
+func TestNewEditor(t *testing.T) {
+	// success
+	e, b := xEditor("")
+	assert.NotNil(t, e.Input)
+	assert.Equal(t, b, e.Output)
+	assert.Empty(t, e.History)
+	assert.Nil(t, e.Term)
+}
+
+func TestEditorAdd(t *testing.T) {
+	// setup
+	e, _ := xEditor("")
+	e.File = filepath.Join(t.TempDir(), "history")
+
+	// success
+	err := e.Add("1 2")
+	assert.Equal(t, []string{"1 2"}, e.History)
+	assert.NoError(t, err)
+
+	// success - empty and repeated lines
+	e.Add(" ")
+	e.Add("1 2")
+	e.Add("3")
+	bs, _ := os.ReadFile(e.File)
+	assert.Equal(t, []string{"1 2", "3"}, e.History)
+	assert.Equal(t, "1 2\n3\n", string(bs))
+
+	// success - capped history file
+	for i := 0; i < HistoryCap; i++ {
+		e.Add(strconv.Itoa(i))
+	}
+
+	bs, _ = os.ReadFile(e.File)
+	assert.Len(t, e.History, HistoryCap)
+	assert.Equal(t, "0", e.History[0])
+	assert.Len(t, strings.Split(strings.TrimSpace(string(bs)), "\n"), HistoryCap)
+
+	// failure - unwritable file
+	e.File = t.TempDir()
+	err = e.Add("4")
+	assert.Error(t, err)
+}
+
+func TestEditorLoad(t *testing.T) {
+	// setup
+	e, _ := xEditor("")
+	p := filepath.Join(t.TempDir(), "history")
+
+	// success - missing file
+	err := e.Load(p)
+	assert.Equal(t, p, e.File)
+	assert.Empty(t, e.History)
+	assert.NoError(t, err)
+
+	// success
+	os.WriteFile(p, []byte("1 2\n3\n"), 0666)
+	err = e.Load(p)
+	assert.Equal(t, []string{"1 2", "3"}, e.History)
+	assert.NoError(t, err)
+
+	// success - trimmed file
+	os.WriteFile(p, []byte(strings.Repeat("1\n", HistoryCap+5)), 0666)
+	err = e.Load(p)
+	bs, _ := os.ReadFile(p)
+	assert.Len(t, e.History, HistoryCap)
+	assert.Equal(t, strings.Repeat("1\n", HistoryCap), string(bs))
+	assert.NoError(t, err)
+}
+
+func TestEditorReadLine(t *testing.T) {
+	// success
+	e, b := xEditor("1 2\r\n3")
+	s, err := e.ReadLine(">>> ")
+	assert.Equal(t, "1 2", s)
+	assert.Equal(t, ">>> ", b.String())
+	assert.NoError(t, err)
+
+	// success - unterminated line
+	s, err = e.ReadLine(">>> ")
+	assert.Equal(t, "3", s)
+	assert.NoError(t, err)
+
+	// failure - end of input
+	s, err = e.ReadLine(">>> ")
+	assert.Empty(t, s)
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestEditorEdit(t *testing.T) {
+	// success - typing
+	e, b := xEditor("12\x7f3\n")
+	s, err := e.edit("> ")
+	assert.Equal(t, "13", s)
+	assert.True(t, strings.HasPrefix(b.String(), "\r> \x1b[K\r> 1\x1b[K"))
+	assert.NoError(t, err)
+
+	// success - cursor keys
+	e, _ = xEditor("13\x1b[D2\x010\x05\x1b[3~4\x1b[H\x1b[C\x0b\n")
+	s, err = e.edit("> ")
+	assert.Equal(t, "0", s)
+	assert.NoError(t, err)
+
+	// success - history
+	e, _ = xEditor("9\x1b[A\x1b[A\x1b[B\x1b[B\x1b[A\n")
+	e.History = []string{"1", "2"}
+	s, err = e.edit("> ")
+	assert.Equal(t, "2", s)
+	assert.NoError(t, err)
+
+	// success - clear line
+	e, _ = xEditor("12\x03\x153\n")
+	s, err = e.edit("> ")
+	assert.Equal(t, "3", s)
+	assert.NoError(t, err)
+
+	// failure - end of input
+	e, _ = xEditor("\x04")
+	s, err = e.edit("> ")
+	assert.Empty(t, s)
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestEditorEscape(t *testing.T) {
+	// success
+	e, _ := xEditor("[3~x")
+	s := e.escape()
+	assert.Equal(t, "[3~", s)
+
+	// success - unknown sequence
+	e, _ = xEditor("x")
+	s = e.escape()
+	assert.Empty(t, s)
+}
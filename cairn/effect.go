@@ -0,0 +1,66 @@
+package cairn
+
+import (
+	"slices"
+	"strings"
+)
+
+// Effect is the stack effect of a function, popping In integers and pushing Out
+// integers, with Var true if the effect depends on the integers popped.
+type Effect struct {
+	In  int
+	Out int
+	Var bool
+}
+
+// Effects is the map of stack effects for the default Cairn program functions, from
+// the Builtins.
+var Effects = builtinMap(func(b Builtin) Effect { return b.Effect })
+
+// ParseEffect returns an Effect from the first "(a b -- c)" stack effect comment in a
+// string, with "..." on one side only making the Effect variable, and false if the
+// string has no stack effect.
+func ParseEffect(s string) (Effect, bool) {
+	i := strings.Index(s, "(")
+	j := strings.Index(s, ")")
+	if i < 0 || j < i {
+		return Effect{}, false
+	}
+
+	in, out, ok := strings.Cut(s[i+1:j], "--")
+	if !ok {
+		return Effect{}, false
+	}
+
+	ins, outs := strings.Fields(in), strings.Fields(out)
+	vi, vo := slices.Contains(ins, "..."), slices.Contains(outs, "...")
+	return Effect{len(ins) - Bool(vi), len(outs) - Bool(vo), vi != vo}, true
+}
+
+// Net returns the change in stack height caused by the Effect.
+func (e Effect) Net() int {
+	return e.Out - e.In
+}
+
+// String returns the Effect as a stack effect comment, with "..." marking a
+// variable effect.
+func (e Effect) String() string {
+	var ss []string
+	for i := 0; i < e.In+e.Out; i++ {
+		if i == e.In {
+			ss = append(ss, "--")
+		}
+
+		ss = append(ss, string(rune('a'+i%26)))
+	}
+
+	if e.Out == 0 {
+		ss = append(ss, "--")
+	}
+
+	if e.Var {
+		ss = append(ss, "...")
+	}
+
+	return "(" + strings.Join(ss, " ") + ")"
+}
@@ -0,0 +1,65 @@
+package cairn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEffects(t *testing.T) {
+	// success - every function has a matching effect
+	for s, b := range Builtins {
+		e, ok := Effects[s]
+		assert.True(t, ok, s)
+		assert.Equal(t, b.Effect, e, s)
+
+		if ce, ok := ParseEffect(b.Doc); ok {
+			assert.Equal(t, ce.In, e.In, s)
+			assert.Equal(t, ce.Out, e.Out, s)
+		}
+	}
+}
+
+func TestParseEffect(t *testing.T) {
+	// success
+	e, ok := ParseEffect("// (a b -- c) Return a thing.")
+	assert.Equal(t, Effect{2, 1, false}, e)
+	assert.True(t, ok)
+
+	// success - empty effect
+	e, ok = ParseEffect("(--)")
+	assert.Equal(t, Effect{0, 0, false}, e)
+	assert.True(t, ok)
+
+	// success - variable effect
+	e, ok = ParseEffect("(0 ... --)")
+	assert.Equal(t, Effect{1, 0, true}, e)
+	assert.True(t, ok)
+
+	// success - balanced variable effect
+	e, ok = ParseEffect("(... a -- ... b)")
+	assert.Equal(t, Effect{1, 1, false}, e)
+	assert.True(t, ok)
+
+	// failure - no effect
+	_, ok = ParseEffect("// Clear registers (0 and 1).")
+	assert.False(t, ok)
+}
+
+func TestEffectNet(t *testing.T) {
+	// success
+	n := Effect{3, 1, false}.Net()
+	assert.Equal(t, -2, n)
+}
+
+func TestEffectString(t *testing.T) {
+	// success
+	for e, want := range map[Effect]string{
+		{0, 0, false}: "(--)",
+		{2, 1, false}: "(a b -- c)",
+		{0, 1, false}: "(-- a)",
+		{1, 0, true}:  "(a -- ...)",
+	} {
+		assert.Equal(t, want, e.String())
+	}
+}
@@ -1,17 +1,121 @@
 package cairn
 
-import "flag"
+import (
+	"flag"
+	"slices"
+	"time"
 
-// Flags is a container for parsed command-line flags.
+	"github.com/wirehaiku/cairn/vm"
+)
+
+// Flags is a container for a parsed subcommand and its command-line flags.
 type Flags struct {
-	Command string
-	Files   []string
+	Name     string
+	Command  string
+	Debug    bool
+	Trace    string
+	Format   string
+	Profile  string
+	MaxDepth int
+	MaxInstr int
+	MaxStack int
+	MaxTable int
+	Timeout  time.Duration
+	Caps     Caps
+	EOF      int
+	In       string
+	Out      string
+	Err      string
+	Append   bool
+	Write    bool
+	Diff     bool
+	JSON     bool
+	Files    []string
 }
 
-// ParseFlags returns a parsed Flags from an argument slice.
+// Commands is the list of subcommand names.
+var Commands = []string{"run", "repl", "test", "fmt", "check", "lint", "lsp", "doc"}
+
+// ParseFlags returns a parsed Flags from an argument slice, using the subcommand
+// named by the first argument, "run" if it names none, or "repl" if there are no
+// arguments.
 func ParseFlags(ss []string) (*Flags, error) {
-	f := flag.NewFlagSet("cairn", flag.ContinueOnError)
-	c := f.String("c", "", "eval string")
+	fs := Flags{
+		Name:     "run",
+		Format:   "text",
+		MaxDepth: vm.DefaultDepth,
+		MaxStack: DefaultModel.StackCap,
+		MaxTable: DefaultModel.TableCap,
+		Caps:     AllCaps,
+		In:       "-",
+		Out:      "-",
+		Err:      "-",
+	}
+
+	switch {
+	case len(ss) == 0:
+		fs.Name = "repl"
+	case slices.Contains(Commands, ss[0]):
+		fs.Name, ss = ss[0], ss[1:]
+	}
+
+	f := flag.NewFlagSet("cairn "+fs.Name, flag.ContinueOnError)
+	switch fs.Name {
+	case "run":
+		fs.runFlags(f)
+		fs.machineFlags(f)
+		fs.streamFlags(f)
+	case "repl", "test":
+		fs.machineFlags(f)
+		fs.streamFlags(f)
+	case "fmt":
+		fs.fmtFlags(f)
+	case "lint":
+		f.BoolVar(&fs.JSON, "json", fs.JSON, "write problems as JSON lines")
+	case "doc":
+		f.StringVar(&fs.Format, "format", fs.Format, "doc format, text, markdown or html")
+	}
+
 	err := f.Parse(ss)
-	return &Flags{*c, f.Args()}, err
+	fs.Files = f.Args()
+	return &fs, err
+}
+
+// fmtFlags defines the flags for formatting files on a FlagSet.
+func (fs *Flags) fmtFlags(f *flag.FlagSet) {
+	f.BoolVar(&fs.Write, "w", fs.Write, "write formatted files in place")
+	f.BoolVar(&fs.Diff, "d", fs.Diff, "show diffs of formatted files")
+}
+
+// machineFlags defines the flags for the Cairn's limits and capabilities on a FlagSet.
+func (fs *Flags) machineFlags(f *flag.FlagSet) {
+	f.IntVar(&fs.MaxDepth, "max-depth", fs.MaxDepth, "maximum call depth, or 0 for unlimited")
+	f.IntVar(&fs.MaxInstr, "max-instr", fs.MaxInstr, "maximum instructions, or 0 for unlimited")
+	f.IntVar(&fs.MaxStack, "max-stack", fs.MaxStack, "maximum stack size, or 0 for unlimited")
+	f.IntVar(&fs.MaxTable, "max-table", fs.MaxTable, "maximum table size, or 0 for unlimited")
+	f.DurationVar(&fs.Timeout, "timeout", fs.Timeout, "maximum run time, or 0 for unlimited")
+	f.Func("caps", "allowed capabilities, as a list of exit, input, output, fs, clock and rand", func(s string) error {
+		cs, err := ParseCaps(s)
+		fs.Caps = cs
+		return err
+	})
+
+	f.IntVar(&fs.EOF, "eof", fs.EOF, "integer read by inn at the end of input")
+}
+
+// runFlags defines the flags for running programs on a FlagSet.
+func (fs *Flags) runFlags(f *flag.FlagSet) {
+	f.StringVar(&fs.Command, "c", fs.Command, "eval string")
+	f.BoolVar(&fs.Debug, "debug", fs.Debug, "debug interactively")
+	f.StringVar(&fs.Trace, "trace", fs.Trace, "trace to file, or - for stderr")
+	f.StringVar(&fs.Format, "trace-format", fs.Format, "trace format, text or json")
+	f.StringVar(&fs.Profile, "profile", fs.Profile, "write pprof profile to file")
+}
+
+// streamFlags defines the flags for input, output and error files on a FlagSet.
+func (fs *Flags) streamFlags(f *flag.FlagSet) {
+	f.StringVar(&fs.In, "in", fs.In, "input file, or - for stdin")
+	f.StringVar(&fs.Out, "out", fs.Out, "output file, or - for stdout")
+	f.StringVar(&fs.Err, "err", fs.Err, "error file, or - for stderr")
+	f.BoolVar(&fs.Append, "append", fs.Append, "append to output and error files")
 }
@@ -2,17 +2,86 @@ package cairn
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/wirehaiku/cairn/vm"
 )
 
 func TestParseFlags(t *testing.T) {
 	// setup
-	ss := []string{"-c", "cmd", "a.txt", "b.txt"}
+	ss := []string{
+		"-c", "cmd", "-debug", "-trace", "-", "-trace-format", "json", "-profile", "p.out",
+		"-max-depth", "1", "-max-instr", "2", "-max-stack", "3", "-max-table", "4",
+		"-timeout", "5s", "-caps", "input,output", "-eof", "6",
+		"-in", "i.txt", "-out", "o.txt", "-err", "e.txt", "-append", "a.txt", "b.txt",
+	}
 
 	// success
 	f, err := ParseFlags(ss)
+	assert.Equal(t, "run", f.Name)
 	assert.Equal(t, "cmd", f.Command)
+	assert.True(t, f.Debug)
+	assert.Equal(t, "-", f.Trace)
+	assert.Equal(t, "json", f.Format)
+	assert.Equal(t, "p.out", f.Profile)
+	assert.Equal(t, 1, f.MaxDepth)
+	assert.Equal(t, 2, f.MaxInstr)
+	assert.Equal(t, 3, f.MaxStack)
+	assert.Equal(t, 4, f.MaxTable)
+	assert.Equal(t, 5*time.Second, f.Timeout)
+	assert.Equal(t, CapInput|CapOutput, f.Caps)
+	assert.Equal(t, 6, f.EOF)
+	assert.Equal(t, "i.txt", f.In)
+	assert.Equal(t, "o.txt", f.Out)
+	assert.Equal(t, "e.txt", f.Err)
+	assert.True(t, f.Append)
 	assert.Equal(t, []string{"a.txt", "b.txt"}, f.Files)
 	assert.NoError(t, err)
+
+	// success - defaults
+	f, err = ParseFlags(nil)
+	assert.Equal(t, "repl", f.Name)
+	assert.Equal(t, AllCaps, f.Caps)
+	assert.Equal(t, vm.DefaultDepth, f.MaxDepth)
+	assert.Equal(t, DefaultModel.StackCap, f.MaxStack)
+	assert.Equal(t, "-", f.In)
+	assert.NoError(t, err)
+
+	// success - subcommands
+	f, err = ParseFlags([]string{"test", "-max-instr", "9", "a.txt"})
+	assert.Equal(t, "test", f.Name)
+	assert.Equal(t, 9, f.MaxInstr)
+	assert.Equal(t, []string{"a.txt"}, f.Files)
+	assert.NoError(t, err)
+
+	f, err = ParseFlags([]string{"fmt", "-w", "-d", "a.txt"})
+	assert.Equal(t, "fmt", f.Name)
+	assert.True(t, f.Write)
+	assert.True(t, f.Diff)
+	assert.Equal(t, []string{"a.txt"}, f.Files)
+	assert.NoError(t, err)
+
+	f, err = ParseFlags([]string{"lint", "-json", "a.txt"})
+	assert.Equal(t, "lint", f.Name)
+	assert.True(t, f.JSON)
+	assert.NoError(t, err)
+
+	f, err = ParseFlags([]string{"doc", "-format", "html"})
+	assert.Equal(t, "doc", f.Name)
+	assert.Equal(t, "html", f.Format)
+	assert.NoError(t, err)
+
+	f, err = ParseFlags([]string{"run", "a.txt"})
+	assert.Equal(t, "run", f.Name)
+	assert.Equal(t, []string{"a.txt"}, f.Files)
+	assert.NoError(t, err)
+
+	// failure - flag for another subcommand
+	_, err = ParseFlags([]string{"check", "-c", "1"})
+	assert.ErrorContains(t, err, "flag provided but not defined: -c")
+
+	// failure - invalid capability
+	_, err = ParseFlags([]string{"-caps", "nope"})
+	assert.ErrorContains(t, err, `capability "nope" does not exist`)
 }
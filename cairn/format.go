@@ -0,0 +1,261 @@
+package cairn
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// formatter is a builder of formatted lines from a token slice.
+type formatter struct {
+	lines  []*fmtLine
+	line   *fmtLine
+	depth  int
+	closed bool
+	fresh  bool
+	blocks []bool
+}
+
+// fmtLine is a formatted line of code tokens and a comment at an indentation depth.
+type fmtLine struct {
+	depth   int
+	code    []string
+	comment string
+}
+
+// Format returns a named program string formatted with upper-case symbols, indented
+// blocks and aligned trailing comments, or an error if it does not parse against a
+// Model.
+func Format(f, s string, m Model) (string, error) {
+	if _, err := ParseString(f, s, m); err != nil {
+		return "", err
+	}
+
+	var ft formatter
+	ts := TokeniseComments(f, s)
+	prev := 0
+
+	for i := 0; i < len(ts); i++ {
+		t := ts[i]
+		if prev != 0 && t.Pos.Line > prev {
+			ft.finish()
+			if t.Pos.Line > prev+1 {
+				ft.blank()
+			}
+		}
+
+		switch k := keyword(t); {
+		case strings.HasPrefix(t.Text, "//"):
+			ft.comment(t.Text)
+
+		case k == "def":
+			ft.finish()
+			j := ft.operand(ts, i)
+			ft.add("DEF", casing(ts[j].Text))
+			ft.open(false)
+			i = j
+
+		case k == "help":
+			j := ft.operand(ts, i)
+			ft.add("HELP", casing(ts[j].Text))
+			i = j
+
+		case k == "for":
+			in := inline(ts, i)
+			j := ft.operand(ts, i)
+			ft.add("FOR", casing(ts[j].Text))
+			ft.open(in)
+			i = j
+
+		case k == "ift", k == "iff", k == "tst":
+			ft.add(strings.ToUpper(k))
+			ft.open(inline(ts, i))
+
+		case k == "end":
+			ft.close()
+
+		default:
+			ft.add(casing(t.Text))
+		}
+
+		prev = ts[i].Pos.Line
+	}
+
+	return ft.String(), nil
+}
+
+// String returns the formatter's lines as a string, with the trailing comments of
+// consecutive lines at the same depth aligned.
+func (ft *formatter) String() string {
+	var b strings.Builder
+	ls := ft.lines
+
+	for i := 0; i < len(ls); {
+		j, w := i, 0
+		for j < len(ls) && ls[j].trailing() && ls[j].depth == ls[i].depth {
+			w = max(w, utf8.RuneCountInString(ls[j].text()))
+			j++
+		}
+
+		if j == i {
+			j = i + 1
+		}
+
+		for _, l := range ls[i:j] {
+			s := l.text()
+			switch {
+			case l.code == nil && l.comment == "":
+				b.WriteString("\n")
+				continue
+			case l.trailing():
+				s += strings.Repeat(" ", w-utf8.RuneCountInString(s)+1) + l.comment
+			case l.code == nil:
+				s = l.comment
+			}
+
+			b.WriteString(strings.Repeat("\t", l.depth) + s + "\n")
+		}
+
+		i = j
+	}
+
+	return b.String()
+}
+
+// add adds code tokens to the formatter's current line, starting a new line if
+// there is none or the current line is closed.
+func (ft *formatter) add(ss ...string) {
+	if ft.closed {
+		ft.finish()
+	}
+
+	if ft.line == nil {
+		ft.line = &fmtLine{depth: ft.depth}
+		ft.lines = append(ft.lines, ft.line)
+	}
+
+	ft.line.code = append(ft.line.code, ss...)
+	ft.fresh = false
+}
+
+// blank adds a blank line to the formatter, unless it follows a blank line or the
+// start of a block.
+func (ft *formatter) blank() {
+	n := len(ft.lines)
+	if ft.fresh || n == 0 || ft.lines[n-1].blank() {
+		return
+	}
+
+	ft.lines = append(ft.lines, &fmtLine{depth: ft.depth})
+}
+
+// close closes the formatter's innermost block, adding its "END" token on its own
+// line unless the block is inline.
+func (ft *formatter) close() {
+	in := ft.blocks[len(ft.blocks)-1]
+	ft.blocks = ft.blocks[:len(ft.blocks)-1]
+	if in {
+		ft.add("END")
+		return
+	}
+
+	ft.finish()
+	for n := len(ft.lines); n > 0 && ft.lines[n-1].blank(); n-- {
+		ft.lines = ft.lines[:n-1]
+	}
+
+	ft.depth--
+	ft.add("END")
+	ft.closed = true
+}
+
+// comment adds a comment to the formatter's current line, or on its own line if
+// there is no current line.
+func (ft *formatter) comment(s string) {
+	if ft.line != nil {
+		ft.line.comment = s
+	} else {
+		ft.lines = append(ft.lines, &fmtLine{depth: ft.depth, comment: s})
+		ft.fresh = false
+	}
+
+	ft.finish()
+}
+
+// finish ends the formatter's current line.
+func (ft *formatter) finish() {
+	ft.line = nil
+	ft.closed = false
+}
+
+// open opens a block in the formatter, indenting the lines after the current line
+// unless the block is inline.
+func (ft *formatter) open(in bool) {
+	ft.blocks = append(ft.blocks, in)
+	if !in {
+		ft.depth++
+		ft.closed = true
+		ft.fresh = true
+	}
+}
+
+// operand returns the index of the operand token following a keyword token at an
+// index, adding the comments between them to the formatter.
+func (ft *formatter) operand(ts []Token, i int) int {
+	j := operand(ts, i)
+	for _, t := range ts[i+1 : j] {
+		ft.comment(t.Text)
+	}
+
+	return j
+}
+
+// blank returns true if the fmtLine is a blank line.
+func (l *fmtLine) blank() bool {
+	return l.code == nil && l.comment == ""
+}
+
+// text returns the fmtLine's code tokens as a string.
+func (l *fmtLine) text() string {
+	return strings.Join(l.code, " ")
+}
+
+// trailing returns true if the fmtLine has both code and a comment.
+func (l *fmtLine) trailing() bool {
+	return l.code != nil && l.comment != ""
+}
+
+// casing returns a token string in upper case, except for string and character
+// literals and the base prefixes of integers.
+func casing(s string) string {
+	if strings.HasPrefix(s, "'") || strings.HasPrefix(s, `"`) {
+		return s
+	}
+
+	u := strings.ToUpper(s)
+	if _, ok, _ := integer(s); ok {
+		t := strings.TrimPrefix(u, "-")
+		if len(t) > 2 && t[0] == '0' && strings.ContainsRune("XOB", rune(t[1])) {
+			return u[:len(u)-len(t)+1] + strings.ToLower(t[1:2]) + t[2:]
+		}
+	}
+
+	return u
+}
+
+// inline returns true if the block opened by a keyword token at an index ends on the
+// same line and contains no function definitions.
+func inline(ts []Token, i int) bool {
+	l, n := ts[i].Pos.Line, 0
+	w := blockWalker{ts: ts, i: i}
+	for b, ok := w.next(); ok && ts[b.index].Pos.Line == l; b, ok = w.next() {
+		if b.key == "def" || b.operand < 0 || b.operand > 0 && ts[b.operand].Pos.Line != l {
+			return false
+		}
+
+		if n += b.depth; b.depth < 0 && n == 0 {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,81 @@
+package cairn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	// setup
+	s := "def three 1 2 add end three // 3\n1 ift 2 end\n\n\n" +
+		"3 4 < ift\n\n   5 // five\n   6 7 // six seven\n\n end 0XFF 'a' \"b c\"\n" +
+		"// full\nfor 0\n0 get end\nhelp three 1 ift help end end\n"
+
+	// success
+	s2, err := Format("", s, DefaultModel)
+	assert.Equal(t, "DEF THREE\n"+
+		"\t1 2 ADD\n"+
+		"END\n"+
+		"THREE // 3\n"+
+		"1 IFT 2 END\n"+
+		"\n"+
+		"3 4 < IFT\n"+
+		"\t5   // five\n"+
+		"\t6 7 // six seven\n"+
+		"END\n"+
+		"0xFF 'a' \"b c\"\n"+
+		"// full\n"+
+		"FOR 0\n"+
+		"\t0 GET\n"+
+		"END\n"+
+		"HELP THREE 1 IFT HELP END END\n", s2)
+	assert.NoError(t, err)
+
+	// success - comments before operands
+	s2, err = Format("", "def // c\nfoo 1 end 2 help // d\nfoo", DefaultModel)
+	assert.Equal(t, "// c\nDEF FOO\n\t1\nEND\n2 // d\nHELP FOO\n", s2)
+	assert.NoError(t, err)
+
+	// success - idempotence
+	for _, s := range []string{s, Library, "def . // no-op\n\tnop\nend\n", "for // r\n0 1 end\n"} {
+		s2, _ := Format("", s, DefaultModel)
+		s3, err := Format("", s2, DefaultModel)
+		assert.Equal(t, s2, s3)
+		assert.NoError(t, err)
+	}
+
+	// failure - parse error
+	s2, err = Format("a", "def foo", DefaultModel)
+	assert.Empty(t, s2)
+	assert.EqualError(t, err, `a:1:1: missing "end"`)
+}
+
+func TestCasing(t *testing.T) {
+	// success
+	for s, want := range map[string]string{
+		"foo":    "FOO",
+		"f?":     "F?",
+		"0xff":   "0xFF",
+		"-0B1_0": "-0b1_0",
+		"'a'":    "'a'",
+		`"b c"`:  `"b c"`,
+		"0x":     "0X",
+		"123":    "123",
+	} {
+		assert.Equal(t, want, casing(s), s)
+	}
+}
+
+func TestInline(t *testing.T) {
+	// success
+	for s, want := range map[string]bool{
+		"ift 1 end":           true,
+		"ift for 0 1 end end": true,
+		"ift 1\nend":          false,
+		"for\n0 1 end":        false,
+		"ift def a end end":   false,
+	} {
+		assert.Equal(t, want, inline(Tokenise("", s), 0), s)
+	}
+}
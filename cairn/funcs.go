@@ -1,135 +1,300 @@
 package cairn
 
 import (
-	"os"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 )
 
-// ExitFunc is the default system exit function.
-var ExitFunc = os.Exit
-
-// Funcs is the default map of Cairn program functions.
-var Funcs = map[string]CairnFunc{
-	"+":   MathAddFunc,
-	"-":   MathSubFunc,
-	"==":  LogicEqualFunc,
-	"<":   MathLesserThanFunc,
-	">":   MathGreaterThanFunc,
-	"die": IOExitFunc,
-	"clr": StackClearFunc,
-	"def": SystemDefineFunc,
-	"eva": SystemEvalFunc,
-	"get": TableGetFunc,
-	"ift": LogicIfTrueFunc,
-	"iff": LogicIfFalseFunc,
-	"for": LogicLoopFunc,
-	"inn": IOReadFunc,
-	"out": IOWriteFunc,
-	"nop": LogicNoOpFunc,
-	"set": TableSetFunc,
+// Builtin is a builtin Cairn program function with its stack effect and its doc
+// comment.
+type Builtin struct {
+	Func   CairnFunc
+	Effect Effect
+	Doc    string
 }
 
-// IOExitFunc (a --) exits the program with an integer exit code.
-func IOExitFunc(c *Cairn) error {
-	return Pure(c, 1, func(is []int) {
-		ExitFunc(is[0])
+// Builtins is the default map of builtin Cairn program functions.
+var Builtins = map[string]Builtin{
+	"+":      {MathAddFunc, Effect{2, 1, false}, "(a b -- c) pushes the sum of the top two integers on the Stack."},
+	"-":      {MathSubFunc, Effect{2, 1, false}, "(a b -- c) pushes the difference of the top two integers on the Stack."},
+	"*":      {MathMulFunc, Effect{2, 1, false}, "(a b -- c) pushes the product of a and b."},
+	"-rot":   {StackRotBackFunc, Effect{3, 3, false}, "(a b c -- c a b) rotates the top integer to third place."},
+	"2drop":  {StackDrop2Func, Effect{2, 0, false}, "(a b --) deletes the top two integers."},
+	"2dup":   {StackDup2Func, Effect{2, 4, false}, "(a b -- a b a b) duplicates the top two integers."},
+	"/":      {MathDivFunc, Effect{2, 1, false}, "(a b -- c) pushes the quotient of a divided by b."},
+	"==":     {LogicEqualFunc, Effect{2, 1, false}, "(a b -- c) pushes true if a == b."},
+	"<":      {MathLesserThanFunc, Effect{2, 1, false}, "(a b -- c) pushes true if a < b."},
+	">":      {MathGreaterThanFunc, Effect{2, 1, false}, "(a b -- c) pushes true if a > b."},
+	"abs":    {MathAbsFunc, Effect{1, 1, false}, "(a -- b) pushes the absolute value of a."},
+	"add":    {MathAddFunc, Effect{2, 1, false}, "(a b -- c) pushes the sum of the top two integers on the Stack."},
+	"and":    {BitAndFunc, Effect{2, 1, false}, "(a b -- c) pushes the bitwise AND of a and b."},
+	"bye":    {IOByeFunc, Effect{0, 0, false}, "(--) exits the program successfully."},
+	"clr":    {StackClearFunc, Effect{0, 0, true}, "(--) clears the Stack."},
+	"depth":  {StackDepthFunc, Effect{0, 1, false}, "(-- a) pushes the number of integers on the Stack."},
+	"die":    {IOExitFunc, Effect{1, 0, false}, "(a --) exits the program with an integer exit code."},
+	"divmod": {MathDivModFunc, Effect{2, 2, false}, "(a b -- c d) pushes the quotient and remainder of a divided by b."},
+	"drop":   {StackDropFunc, Effect{1, 0, false}, "(a --) deletes the top integer."},
+	"dup":    {StackDupFunc, Effect{1, 2, false}, "(a -- a a) duplicates the top integer."},
+	"eof?":   {IOEOFFunc, Effect{0, 1, false}, "(-- a) pushes true if the input is at its end."},
+	"equ":    {LogicEqualFunc, Effect{2, 1, false}, "(a b -- c) pushes true if a == b."},
+	"eva":    {SystemEvalFunc, Effect{0, 0, true}, "(... --) evaluates all integers in the Stack up to a newline as a string."},
+	"get":    {TableGetFunc, Effect{1, 1, false}, "(a -- b) pushes a value from the Table."},
+	"gte":    {MathGreaterEqualFunc, Effect{2, 1, false}, "(a b -- c) pushes true if a >= b."},
+	"inn":    {IOReadFunc, Effect{0, 1, false}, "(-- a) pushes an input character as an integer, or the EOF sentinel at the end of input."},
+	"line":   {IOLineFunc, Effect{0, 1, true}, "(-- ... a) pushes an input line without its line ending as a string, or an empty string at the end of input."},
+	"max":    {MathMaxFunc, Effect{2, 1, false}, "(a b -- c) pushes the larger of a and b."},
+	"min":    {MathMinFunc, Effect{2, 1, false}, "(a b -- c) pushes the smaller of a and b."},
+	"mod":    {MathModFunc, Effect{2, 1, false}, "(a b -- c) pushes the remainder of a divided by b."},
+	"neg":    {MathNegFunc, Effect{1, 1, false}, "(a -- b) pushes the negation of a."},
+	"nip":    {StackNipFunc, Effect{2, 1, false}, "(a b -- b) deletes the integer below the top."},
+	"nop":    {LogicNoOpFunc, Effect{0, 0, false}, "does nothing."},
+	"not":    {BitNotFunc, Effect{1, 1, false}, "(a -- b) pushes the bitwise NOT of a."},
+	"or":     {BitOrFunc, Effect{2, 1, false}, "(a b -- c) pushes the bitwise OR of a and b."},
+	"out":    {IOWriteFunc, Effect{1, 0, false}, "(a --) writes an integer as an output character."},
+	"over":   {StackOverFunc, Effect{2, 3, false}, "(a b -- a b a) copies the integer below the top to the top."},
+	"pick":   {StackPickFunc, Effect{1, 1, true}, "(... a -- ... b) copies the integer a places below the top to the top."},
+	"print":  {IOPrintFunc, Effect{1, 0, true}, "(0 ... --) writes all integers in the Stack down to a zero as a string."},
+	"rol":    {BitRotateLeftFunc, Effect{2, 1, false}, "(a b -- c) pushes a with its bits rotated left by b."},
+	"roll":   {StackRollFunc, Effect{1, 0, true}, "(... a -- ...) moves the integer a places below the top to the top."},
+	"ror":    {BitRotateRightFunc, Effect{2, 1, false}, "(a b -- c) pushes a with its bits rotated right by b."},
+	"rot":    {StackRotFunc, Effect{3, 3, false}, "(a b c -- b c a) rotates the third integer to the top."},
+	"set":    {TableSetFunc, Effect{2, 0, false}, "(a b --) sets a value in the Table."},
+	"shl":    {BitShiftLeftFunc, Effect{2, 1, false}, "(a b -- c) pushes a with its bits shifted left by b."},
+	"shr":    {BitShiftRightFunc, Effect{2, 1, false}, "(a b -- c) pushes a with its bits shifted right by b."},
+	"sub":    {MathSubFunc, Effect{2, 1, false}, "(a b -- c) pushes the difference of the top two integers on the Stack."},
+	"swap":   {StackSwapFunc, Effect{2, 2, false}, "(a b -- b a) swaps the top two integers."},
+	"tuck":   {StackTuckFunc, Effect{2, 3, false}, "(a b -- b a b) copies the top integer below the integer under it."},
+	"type":   {IOTypeFunc, Effect{1, 0, true}, "(... a --) writes the top a integers in the Stack as a string."},
+	"xor":    {BitXorFunc, Effect{2, 1, false}, "(a b -- c) pushes the bitwise XOR of a and b."},
+}
+
+// Funcs is the default map of Cairn program functions, from the Builtins.
+var Funcs = builtinMap(func(b Builtin) CairnFunc { return b.Func })
+
+// BitAndFunc (a b -- c) pushes the bitwise AND of a and b.
+func BitAndFunc(c *Cairn) error {
+	return PurePush(c, 2, func(is []int) int {
+		return is[1] & is[0]
 	})
 }
 
-// IOReadFunc (-- a) pushes an input character as an integer.
-func IOReadFunc(c *Cairn) error {
-	r := c.Read()
-	c.Stack.Push(int(r))
-	return nil
+// BitNotFunc (a -- b) pushes the bitwise NOT of a.
+func BitNotFunc(c *Cairn) error {
+	return PurePush(c, 1, func(is []int) int {
+		return c.Model.Wrap(^is[0])
+	})
 }
 
-// IOWriteFunc (a --) writes an integer as an output character.
-func IOWriteFunc(c *Cairn) error {
-	return Pure(c, 1, func(is []int) {
-		r := rune(is[0])
-		c.Write(r)
+// BitOrFunc (a b -- c) pushes the bitwise OR of a and b.
+func BitOrFunc(c *Cairn) error {
+	return PurePush(c, 2, func(is []int) int {
+		return is[1] | is[0]
 	})
 }
 
-// LogicEqualFunc (a b -- c) pushes true if a == b.
-func LogicEqualFunc(c *Cairn) error {
+// BitRotateLeftFunc (a b -- c) pushes a with its bits rotated left by b.
+func BitRotateLeftFunc(c *Cairn) error {
 	return PurePush(c, 2, func(is []int) int {
-		return Bool(is[1] == is[0])
+		return c.Model.Rotate(is[1], is[0])
 	})
 }
 
-// LogicIfFalseFunc (a --) evaluates code if a is false.
-func LogicIfFalseFunc(c *Cairn) error {
-	i, err := c.Stack.Pop()
-	if err != nil {
+// BitRotateRightFunc (a b -- c) pushes a with its bits rotated right by b.
+func BitRotateRightFunc(c *Cairn) error {
+	return PurePush(c, 2, func(is []int) int {
+		return c.Model.Rotate(is[1], -is[0])
+	})
+}
+
+// BitShiftLeftFunc (a b -- c) pushes a with its bits shifted left by b.
+func BitShiftLeftFunc(c *Cairn) error {
+	return PurePushAll(c, 2, func(is []int) ([]int, error) {
+		if is[0] < 0 {
+			return nil, fmt.Errorf("negative shift %d", is[0])
+		}
+
+		return []int{c.Model.Wrap(is[1] << is[0])}, nil
+	})
+}
+
+// BitShiftRightFunc (a b -- c) pushes a with its bits shifted right by b.
+func BitShiftRightFunc(c *Cairn) error {
+	return PurePushAll(c, 2, func(is []int) ([]int, error) {
+		if is[0] < 0 {
+			return nil, fmt.Errorf("negative shift %d", is[0])
+		}
+
+		return []int{is[1] >> is[0]}, nil
+	})
+}
+
+// BitXorFunc (a b -- c) pushes the bitwise XOR of a and b.
+func BitXorFunc(c *Cairn) error {
+	return PurePush(c, 2, func(is []int) int {
+		return is[1] ^ is[0]
+	})
+}
+
+// IOByeFunc (--) exits the program successfully.
+func IOByeFunc(c *Cairn) error {
+	if err := c.Allow(CapExit); err != nil {
 		return err
 	}
 
-	as, err := DequeueEnd(c.Queue)
-	if err != nil {
+	return &ExitError{0}
+}
+
+// IOEOFFunc (-- a) pushes true if the input is at its end.
+func IOEOFFunc(c *Cairn) error {
+	if err := c.Allow(CapInput); err != nil {
 		return err
 	}
 
-	if i == 0 {
-		return c.EvaluateAll(as)
+	_, err := c.Input.Peek(1)
+	switch {
+	case errors.Is(err, io.EOF):
+		return c.Push(1)
+	case err != nil:
+		return err
+	default:
+		return c.Push(0)
 	}
+}
 
-	return nil
+// IOExitFunc (a --) exits the program with an integer exit code.
+func IOExitFunc(c *Cairn) error {
+	if err := c.Allow(CapExit); err != nil {
+		return err
+	}
+
+	return Pure(c, 1, func(is []int) error {
+		return &ExitError{is[0]}
+	})
 }
 
-// LogicIfTrueFunc (a --) evaluates code if a is true.
-func LogicIfTrueFunc(c *Cairn) error {
-	i, err := c.Stack.Pop()
-	if err != nil {
+// IOLineFunc (-- ... a) pushes an input line without its line ending as a string,
+// or an empty string at the end of input.
+func IOLineFunc(c *Cairn) error {
+	if err := c.Allow(CapInput); err != nil {
+		return err
+	}
+
+	s, err := c.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
 		return err
 	}
 
-	as, err := DequeueEnd(c.Queue)
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "\n"), "\r")
+	if _, hi := c.Model.Range(); len(s) > hi {
+		return fmt.Errorf("line length %d is out of range for %s word", len(s), c.Model)
+	}
+
+	for _, b := range []byte(s) {
+		if err := c.Push(int(b)); err != nil {
+			return err
+		}
+	}
+
+	return c.Push(len(s))
+}
+
+// IOPrintFunc (0 ... --) writes all integers in the Stack down to a zero as a string.
+func IOPrintFunc(c *Cairn) error {
+	if err := c.Allow(CapOutput); err != nil {
+		return err
+	}
+
+	is, err := c.Stack.PopTo(0)
 	if err != nil {
 		return err
 	}
 
-	if i != 0 {
-		return c.EvaluateAll(as)
+	var bs []byte
+	for i := len(is) - 2; i >= 0; i-- {
+		bs = append(bs, byte(is[i]))
 	}
 
+	c.Output.Write(bs)
 	return nil
 }
 
-// LogicLoopFunc (--) repeats code until a register is zero.
-func LogicLoopFunc(c *Cairn) error {
-	a, err := c.Queue.Dequeue()
-	if err != nil {
+// IOReadFunc (-- a) pushes an input character as an integer, or the EOF sentinel at
+// the end of input.
+func IOReadFunc(c *Cairn) error {
+	if err := c.Allow(CapInput); err != nil {
 		return err
 	}
 
-	i, err := ToInteger(a)
-	if err != nil {
+	r, err := c.Read()
+	switch {
+	case errors.Is(err, io.EOF):
+		return c.Push(c.EOF)
+	case err != nil:
 		return err
+	default:
+		return c.Push(int(r))
 	}
+}
 
-	as, err := DequeueEnd(c.Queue)
+// IOTypeFunc (... a --) writes the top a integers in the Stack as a string.
+func IOTypeFunc(c *Cairn) error {
+	if err := c.Allow(CapOutput); err != nil {
+		return err
+	}
+
+	n, err := c.Stack.Pop()
 	if err != nil {
 		return err
 	}
 
-	for {
-		if err := c.EvaluateAll(as); err != nil {
-			return err
-		}
+	is, err := c.Stack.PopN(n)
+	if err != nil {
+		return err
+	}
 
-		if c.Table.Get(i) == 0 {
-			break
-		}
+	var bs []byte
+	for i := len(is) - 1; i >= 0; i-- {
+		bs = append(bs, byte(is[i]))
 	}
 
+	c.Output.Write(bs)
 	return nil
 }
 
+// IOWriteFunc (a --) writes an integer as an output character.
+func IOWriteFunc(c *Cairn) error {
+	if err := c.Allow(CapOutput); err != nil {
+		return err
+	}
+
+	return Pure(c, 1, func(is []int) error {
+		r := rune(is[0])
+		c.Write(r)
+		return nil
+	})
+}
+
+// LogicEqualFunc (a b -- c) pushes true if a == b.
+func LogicEqualFunc(c *Cairn) error {
+	return PurePush(c, 2, func(is []int) int {
+		return Bool(is[1] == is[0])
+	})
+}
+
 // LogicNoOpFunc does nothing.
 func LogicNoOpFunc(c *Cairn) error {
 	return nil
 }
 
+// MathAbsFunc (a -- b) pushes the absolute value of a.
+func MathAbsFunc(c *Cairn) error {
+	return PurePush(c, 1, func(is []int) int {
+		return max(is[0], -is[0])
+	})
+}
+
 // MathAddFunc (a b -- c) pushes the sum of the top two integers on the Stack.
 func MathAddFunc(c *Cairn) error {
 	return PurePush(c, 2, func(is []int) int {
@@ -137,6 +302,35 @@ func MathAddFunc(c *Cairn) error {
 	})
 }
 
+// MathDivFunc (a b -- c) pushes the quotient of a divided by b.
+func MathDivFunc(c *Cairn) error {
+	return PurePushAll(c, 2, func(is []int) ([]int, error) {
+		if is[0] == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+
+		return []int{is[1] / is[0]}, nil
+	})
+}
+
+// MathDivModFunc (a b -- c d) pushes the quotient and remainder of a divided by b.
+func MathDivModFunc(c *Cairn) error {
+	return PurePushAll(c, 2, func(is []int) ([]int, error) {
+		if is[0] == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+
+		return []int{is[1] / is[0], is[1] % is[0]}, nil
+	})
+}
+
+// MathGreaterEqualFunc (a b -- c) pushes true if a >= b.
+func MathGreaterEqualFunc(c *Cairn) error {
+	return PurePush(c, 2, func(is []int) int {
+		return Bool(is[1] >= is[0])
+	})
+}
+
 // MathGreaterThanFunc (a b -- c) pushes true if a > b.
 func MathGreaterThanFunc(c *Cairn) error {
 	return PurePush(c, 2, func(is []int) int {
@@ -151,6 +345,45 @@ func MathLesserThanFunc(c *Cairn) error {
 	})
 }
 
+// MathMaxFunc (a b -- c) pushes the larger of a and b.
+func MathMaxFunc(c *Cairn) error {
+	return PurePush(c, 2, func(is []int) int {
+		return max(is[1], is[0])
+	})
+}
+
+// MathMinFunc (a b -- c) pushes the smaller of a and b.
+func MathMinFunc(c *Cairn) error {
+	return PurePush(c, 2, func(is []int) int {
+		return min(is[1], is[0])
+	})
+}
+
+// MathModFunc (a b -- c) pushes the remainder of a divided by b.
+func MathModFunc(c *Cairn) error {
+	return PurePushAll(c, 2, func(is []int) ([]int, error) {
+		if is[0] == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+
+		return []int{is[1] % is[0]}, nil
+	})
+}
+
+// MathMulFunc (a b -- c) pushes the product of a and b.
+func MathMulFunc(c *Cairn) error {
+	return PurePush(c, 2, func(is []int) int {
+		return is[1] * is[0]
+	})
+}
+
+// MathNegFunc (a -- b) pushes the negation of a.
+func MathNegFunc(c *Cairn) error {
+	return PurePush(c, 1, func(is []int) int {
+		return -is[0]
+	})
+}
+
 // MathSubFunc (a b -- c) pushes the difference of the top two integers on the Stack.
 func MathSubFunc(c *Cairn) error {
 	return PurePush(c, 2, func(is []int) int {
@@ -164,25 +397,104 @@ func StackClearFunc(c *Cairn) error {
 	return nil
 }
 
-// SystemDefineFunc (--) sets a function in the Cairn.
-func SystemDefineFunc(c *Cairn) error {
-	a, err := c.Queue.Dequeue()
+// StackDepthFunc (-- a) pushes the number of integers on the Stack.
+func StackDepthFunc(c *Cairn) error {
+	return c.Push(c.Stack.Len())
+}
+
+// StackDropFunc (a --) deletes the top integer.
+func StackDropFunc(c *Cairn) error {
+	_, err := c.Stack.Pop()
+	return err
+}
+
+// StackDrop2Func (a b --) deletes the top two integers.
+func StackDrop2Func(c *Cairn) error {
+	_, err := c.Stack.PopN(2)
+	return err
+}
+
+// StackDupFunc (a -- a a) duplicates the top integer.
+func StackDupFunc(c *Cairn) error {
+	i, err := c.Stack.Peek()
 	if err != nil {
 		return err
 	}
 
-	s, err := ToSymbol(a)
+	return c.Push(i)
+}
+
+// StackDup2Func (a b -- a b a b) duplicates the top two integers.
+func StackDup2Func(c *Cairn) error {
+	return PurePushAll(c, 2, func(is []int) ([]int, error) {
+		return []int{is[1], is[0], is[1], is[0]}, nil
+	})
+}
+
+// StackNipFunc (a b -- b) deletes the integer below the top.
+func StackNipFunc(c *Cairn) error {
+	return PurePush(c, 2, func(is []int) int {
+		return is[0]
+	})
+}
+
+// StackOverFunc (a b -- a b a) copies the integer below the top to the top.
+func StackOverFunc(c *Cairn) error {
+	i, err := c.Stack.Pick(1)
 	if err != nil {
 		return err
 	}
 
-	as, err := DequeueEnd(c.Queue)
+	return c.Push(i)
+}
+
+// StackPickFunc (... a -- ... b) copies the integer a places below the top to the top.
+func StackPickFunc(c *Cairn) error {
+	n, err := c.Stack.Pop()
 	if err != nil {
 		return err
 	}
 
-	c.SetFuncAtoms(s, as)
-	return nil
+	i, err := c.Stack.Pick(n)
+	if err != nil {
+		return err
+	}
+
+	return c.Push(i)
+}
+
+// StackRollFunc (... a -- ...) moves the integer a places below the top to the top.
+func StackRollFunc(c *Cairn) error {
+	n, err := c.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	return c.Stack.Roll(n)
+}
+
+// StackRotFunc (a b c -- b c a) rotates the third integer to the top.
+func StackRotFunc(c *Cairn) error {
+	return c.Stack.Roll(2)
+}
+
+// StackRotBackFunc (a b c -- c a b) rotates the top integer to third place.
+func StackRotBackFunc(c *Cairn) error {
+	return PurePushAll(c, 3, func(is []int) ([]int, error) {
+		return []int{is[0], is[2], is[1]}, nil
+	})
+}
+
+// StackSwapFunc (a b -- b a) swaps the top two integers.
+func StackSwapFunc(c *Cairn) error {
+	return c.Stack.Roll(1)
+}
+
+// StackTuckFunc (a b -- b a b) copies the top integer below the integer under it.
+func StackTuckFunc(c *Cairn) error {
+	return PurePushAll(c, 2, func(is []int) ([]int, error) {
+		return []int{is[0], is[1], is[0]}, nil
+	})
 }
 
 // SystemEvalFunc (... --) evaluates all integers in the Stack up to a newline as a string.
@@ -197,21 +509,35 @@ func SystemEvalFunc(c *Cairn) error {
 		rs = append(rs, rune(i))
 	}
 
-	ss := Tokenise(string(rs))
-	as := AtomiseAll(ss)
-	return c.EvaluateAll(as)
+	b, err := ParseString("", string(rs), c.Model)
+	if err != nil {
+		return err
+	}
+
+	return c.EvaluateAll(b)
 }
 
 // TableGetFunc (a -- b) pushes a value from the Table.
 func TableGetFunc(c *Cairn) error {
-	return PurePush(c, 1, func(is []int) int {
-		return c.Table.Get(is[0])
+	return PurePushAll(c, 1, func(is []int) ([]int, error) {
+		i, err := c.Get(is[0])
+		return []int{i}, err
 	})
 }
 
 // TableSetFunc (a b --) sets a value in the Table.
 func TableSetFunc(c *Cairn) error {
-	return Pure(c, 2, func(is []int) {
-		c.Table.Set(is[0], is[1])
+	return Pure(c, 2, func(is []int) error {
+		return c.Set(is[0], is[1])
 	})
 }
+
+// builtinMap returns a map of a field of each of the Builtins.
+func builtinMap[T any](f func(Builtin) T) map[string]T {
+	m := make(map[string]T, len(Builtins))
+	for s, b := range Builtins {
+		m[s] = f(b)
+	}
+
+	return m
+}
@@ -1,22 +1,249 @@
 package cairn
 
 import (
+	"bufio"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/wirehaiku/cairn/vm"
 )
 
+func TestBitAndFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{0b1100, 0b1010})
+
+	// success
+	err := BitAndFunc(c)
+	assert.Equal(t, []int{0b1000}, c.Stack.Integers)
+	assert.NoError(t, err)
+}
+
+func TestBitNotFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{0b11110000})
+
+	// success
+	err := BitNotFunc(c)
+	assert.Equal(t, []int{0b00001111}, c.Stack.Integers)
+	assert.NoError(t, err)
+}
+
+func TestBitOrFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{0b1100, 0b1010})
+
+	// success
+	err := BitOrFunc(c)
+	assert.Equal(t, []int{0b1110}, c.Stack.Integers)
+	assert.NoError(t, err)
+}
+
+func TestBitRotateLeftFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{0b10000001, 1})
+
+	// success
+	err := BitRotateLeftFunc(c)
+	assert.Equal(t, []int{0b00000011}, c.Stack.Integers)
+	assert.NoError(t, err)
+}
+
+func TestBitRotateRightFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{0b10000001, 1})
+
+	// success
+	err := BitRotateRightFunc(c)
+	assert.Equal(t, []int{0b11000000}, c.Stack.Integers)
+	assert.NoError(t, err)
+}
+
+func TestBitShiftLeftFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{0b10000001, 1})
+
+	// success
+	err := BitShiftLeftFunc(c)
+	assert.Equal(t, []int{0b00000010}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// failure - negative shift
+	c.Model.Signed = true
+	c.Stack.PushAll([]int{1, -1})
+	err = BitShiftLeftFunc(c)
+	assert.EqualError(t, err, "negative shift -1")
+}
+
+func TestBitShiftRightFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{0b10000001, 1})
+
+	// success
+	err := BitShiftRightFunc(c)
+	assert.Equal(t, []int{0b01000000}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// failure - negative shift
+	c.Model.Signed = true
+	c.Stack.PushAll([]int{1, -1})
+	err = BitShiftRightFunc(c)
+	assert.EqualError(t, err, "negative shift -1")
+}
+
+func TestBitXorFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{0b1100, 0b1010})
+
+	// success
+	err := BitXorFunc(c)
+	assert.Equal(t, []int{0b0110}, c.Stack.Integers)
+	assert.NoError(t, err)
+}
+
+func TestBuiltins(t *testing.T) {
+	// setup
+	af, err := parser.ParseFile(token.NewFileSet(), "funcs.go", nil, parser.ParseComments)
+	assert.NoError(t, err)
+
+	docs := make(map[string]string)
+	for _, d := range af.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok && fd.Doc != nil {
+			s := strings.Join(strings.Fields(fd.Doc.Text()), " ")
+			docs[fd.Name.Name] = strings.TrimPrefix(s, fd.Name.Name+" ")
+		}
+	}
+
+	// success - every doc matches its function's doc comment
+	for s, b := range Builtins {
+		n := runtime.FuncForPC(reflect.ValueOf(b.Func).Pointer()).Name()
+		n = n[strings.LastIndex(n, ".")+1:]
+		assert.Equal(t, docs[n], b.Doc, s)
+	}
+}
+
+func TestIOByeFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+
+	// success
+	err := IOByeFunc(c)
+	assert.Equal(t, &ExitError{0}, err)
+
+	// failure - not allowed
+	c.Caps = 0
+	err = IOByeFunc(c)
+	assert.EqualError(t, err, "exit capability is not allowed")
+}
+
+func TestIOEOFFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("a")
+
+	// success - not at end
+	err := IOEOFFunc(c)
+	assert.Equal(t, []int{0}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// success - at end
+	c.Stack.Clear()
+	IOReadFunc(c)
+	err = IOEOFFunc(c)
+	assert.Equal(t, []int{'a', 1}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// failure - input error
+	c.Input = bufio.NewReader(iotest.ErrReader(errors.New("test")))
+	err = IOEOFFunc(c)
+	assert.EqualError(t, err, "test")
+
+	// failure - not allowed
+	c.Caps = 0
+	err = IOEOFFunc(c)
+	assert.EqualError(t, err, "input capability is not allowed")
+}
+
 func TestIOExitFunc(t *testing.T) {
 	// setup
-	var x int
 	c, _ := xCairn("")
 	c.Stack.Push(123)
-	ExitFunc = func(i int) { x = i }
 
 	// success
 	err := IOExitFunc(c)
-	assert.Equal(t, 123, x)
+	assert.Equal(t, &ExitError{123}, err)
+
+	// failure - not allowed
+	c.Caps = 0
+	err = IOExitFunc(c)
+	assert.EqualError(t, err, "exit capability is not allowed")
+}
+
+func TestIOLineFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("hi\r\nx")
+
+	// success
+	err := IOLineFunc(c)
+	assert.Equal(t, []int{'h', 'i', 2}, c.Stack.Integers)
 	assert.NoError(t, err)
+
+	// success - shared input
+	IOReadFunc(c)
+	assert.Equal(t, []int{'h', 'i', 2, 'x'}, c.Stack.Integers)
+
+	// success - end of input
+	c.Stack.Clear()
+	err = IOLineFunc(c)
+	assert.Equal(t, []int{0}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// failure - line too long
+	c.Input = bufio.NewReader(strings.NewReader(strings.Repeat("a", 256)))
+	err = IOLineFunc(c)
+	assert.EqualError(t, err, "line length 256 is out of range for unsigned 8-bit word")
+
+	// failure - input error
+	c.Input = bufio.NewReader(iotest.ErrReader(errors.New("test")))
+	err = IOLineFunc(c)
+	assert.EqualError(t, err, "test")
+
+	// failure - not allowed
+	c.Caps = 0
+	err = IOLineFunc(c)
+	assert.EqualError(t, err, "input capability is not allowed")
+}
+
+func TestIOPrintFunc(t *testing.T) {
+	// setup
+	c, b := xCairn("")
+	c.Stack.PushAll([]int{1, 0, 'h', 'i'})
+
+	// success
+	err := IOPrintFunc(c)
+	assert.Equal(t, "hi", b.String())
+	assert.Equal(t, []int{1}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// failure - not allowed
+	c.Caps = 0
+	err = IOPrintFunc(c)
+	assert.EqualError(t, err, "output capability is not allowed")
 }
 
 func TestIOReadFunc(t *testing.T) {
@@ -27,6 +254,45 @@ func TestIOReadFunc(t *testing.T) {
 	err := IOReadFunc(c)
 	assert.Equal(t, []int{116}, c.Stack.Integers)
 	assert.NoError(t, err)
+
+	// success - end of input
+	c, _ = xCairn("")
+	c.EOF = 255
+	err = IOReadFunc(c)
+	assert.Equal(t, []int{255}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// failure - input error
+	c.Input = bufio.NewReader(iotest.ErrReader(errors.New("test")))
+	err = IOReadFunc(c)
+	assert.EqualError(t, err, "test")
+
+	// failure - not allowed
+	c.Caps = AllCaps &^ CapInput
+	err = IOReadFunc(c)
+	assert.EqualError(t, err, "input capability is not allowed")
+}
+
+func TestIOTypeFunc(t *testing.T) {
+	// setup
+	c, b := xCairn("")
+	c.Stack.PushAll([]int{1, 'h', 'i', 2})
+
+	// success
+	err := IOTypeFunc(c)
+	assert.Equal(t, "hi", b.String())
+	assert.Equal(t, []int{1}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// failure - stack is empty
+	c.Stack.PushAll([]int{2})
+	err = IOTypeFunc(c)
+	assert.EqualError(t, err, "stack is empty")
+
+	// failure - not allowed
+	c.Caps = 0
+	err = IOTypeFunc(c)
+	assert.EqualError(t, err, "output capability is not allowed")
 }
 
 func TestIOWriteFunc(t *testing.T) {
@@ -38,6 +304,11 @@ func TestIOWriteFunc(t *testing.T) {
 	err := IOWriteFunc(c)
 	assert.Equal(t, "t", b.String())
 	assert.NoError(t, err)
+
+	// failure - not allowed
+	c.Caps = 0
+	err = IOWriteFunc(c)
+	assert.ErrorIs(t, err, fs.ErrPermission)
 }
 
 func TestLogicEqualFunc(t *testing.T) {
@@ -60,82 +331,94 @@ func TestLogicEqualFunc(t *testing.T) {
 	assert.NoError(t, err)
 }
 
-func TestLogicIfFalseFunc(t *testing.T) {
+func TestLogicNoOpFunc(t *testing.T) {
 	// setup
 	c, _ := xCairn("")
-	c.Queue.EnqueueAll([]any{123, "end"})
-	c.Stack.Push(0)
 
-	// success - true
-	err := LogicIfFalseFunc(c)
-	assert.Empty(t, c.Queue.Atoms)
-	assert.Equal(t, []int{123}, c.Stack.Integers)
+	// success
+	err := LogicNoOpFunc(c)
 	assert.NoError(t, err)
+}
 
+func TestMathAbsFunc(t *testing.T) {
 	// setup
-	c.Queue.EnqueueAll([]any{123, "end"})
-	c.Stack.Clear()
-	c.Stack.Push(1)
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{3})
 
-	// success - false
-	err = LogicIfFalseFunc(c)
-	assert.Empty(t, c.Queue.Atoms)
-	assert.Empty(t, c.Stack.Integers)
+	// success
+	err := MathAbsFunc(c)
+	assert.Equal(t, []int{3}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// success - signed
+	c.Model.Signed = true
+	c.Stack.Clear()
+	c.Stack.PushAll([]int{-3})
+	err = MathAbsFunc(c)
+	assert.Equal(t, []int{3}, c.Stack.Integers)
 	assert.NoError(t, err)
 }
 
-func TestLogicIfTrueFunc(t *testing.T) {
+func TestMathAddFunc(t *testing.T) {
 	// setup
 	c, _ := xCairn("")
-	c.Queue.EnqueueAll([]any{123, "end"})
-	c.Stack.Push(1)
+	c.Stack.PushAll([]int{1, 2})
 
-	// success - true
-	err := LogicIfTrueFunc(c)
-	assert.Empty(t, c.Queue.Atoms)
-	assert.Equal(t, []int{123}, c.Stack.Integers)
+	// success
+	err := MathAddFunc(c)
+	assert.Equal(t, []int{3}, c.Stack.Integers)
 	assert.NoError(t, err)
+}
 
+func TestMathDivFunc(t *testing.T) {
 	// setup
-	c.Queue.EnqueueAll([]any{123, "end"})
-	c.Stack.Clear()
-	c.Stack.Push(0)
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{7, 2})
 
-	// success - false
-	err = LogicIfTrueFunc(c)
-	assert.Empty(t, c.Queue.Atoms)
-	assert.Empty(t, c.Stack.Integers)
+	// success
+	err := MathDivFunc(c)
+	assert.Equal(t, []int{3}, c.Stack.Integers)
 	assert.NoError(t, err)
+
+	// failure - division by zero
+	c.Stack.PushAll([]int{1, 0})
+	err = MathDivFunc(c)
+	assert.EqualError(t, err, "division by zero")
 }
 
-func TestLogicLoopFunc(t *testing.T) {
+func TestMathDivModFunc(t *testing.T) {
 	// setup
 	c, _ := xCairn("")
-	c.Queue.EnqueueAll([]any{0, 123, "end"})
+	c.Stack.PushAll([]int{7, 2})
 
 	// success
-	err := LogicLoopFunc(c)
-	assert.Equal(t, []int{123}, c.Stack.Integers)
+	err := MathDivModFunc(c)
+	assert.Equal(t, []int{3, 1}, c.Stack.Integers)
 	assert.NoError(t, err)
+
+	// failure - division by zero
+	c.Stack.PushAll([]int{1, 0})
+	err = MathDivModFunc(c)
+	assert.EqualError(t, err, "division by zero")
 }
 
-func TestLogicNoOpFunc(t *testing.T) {
+func TestMathGreaterEqualFunc(t *testing.T) {
 	// setup
 	c, _ := xCairn("")
+	c.Stack.PushAll([]int{2, 2})
 
-	// success
-	err := LogicNoOpFunc(c)
+	// success - true
+	err := MathGreaterEqualFunc(c)
+	assert.Equal(t, []int{1}, c.Stack.Integers)
 	assert.NoError(t, err)
-}
 
-func TestMathAddFunc(t *testing.T) {
 	// setup
-	c, _ := xCairn("")
+	c.Stack.Clear()
 	c.Stack.PushAll([]int{1, 2})
 
-	// success
-	err := MathAddFunc(c)
-	assert.Equal(t, []int{3}, c.Stack.Integers)
+	// success - false
+	err = MathGreaterEqualFunc(c)
+	assert.Equal(t, []int{0}, c.Stack.Integers)
 	assert.NoError(t, err)
 }
 
@@ -179,6 +462,66 @@ func TestMathLesserThanFunc(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestMathMaxFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{1, 2})
+
+	// success
+	err := MathMaxFunc(c)
+	assert.Equal(t, []int{2}, c.Stack.Integers)
+	assert.NoError(t, err)
+}
+
+func TestMathMinFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{1, 2})
+
+	// success
+	err := MathMinFunc(c)
+	assert.Equal(t, []int{1}, c.Stack.Integers)
+	assert.NoError(t, err)
+}
+
+func TestMathModFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{7, 3})
+
+	// success
+	err := MathModFunc(c)
+	assert.Equal(t, []int{1}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// failure - division by zero
+	c.Stack.PushAll([]int{0})
+	err = MathModFunc(c)
+	assert.EqualError(t, err, "division by zero")
+}
+
+func TestMathMulFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{2, 3})
+
+	// success
+	err := MathMulFunc(c)
+	assert.Equal(t, []int{6}, c.Stack.Integers)
+	assert.NoError(t, err)
+}
+
+func TestMathNegFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{1})
+
+	// success
+	err := MathNegFunc(c)
+	assert.Equal(t, []int{255}, c.Stack.Integers)
+	assert.NoError(t, err)
+}
+
 func TestMathSubFunc(t *testing.T) {
 	// setup
 	c, _ := xCairn("")
@@ -188,6 +531,12 @@ func TestMathSubFunc(t *testing.T) {
 	err := MathSubFunc(c)
 	assert.Equal(t, []int{1}, c.Stack.Integers)
 	assert.NoError(t, err)
+
+	// success - wrapped
+	c.Stack.PushAll([]int{2})
+	err = MathSubFunc(c)
+	assert.Equal(t, []int{255}, c.Stack.Integers)
+	assert.NoError(t, err)
 }
 
 func TestStackClearFunc(t *testing.T) {
@@ -201,20 +550,194 @@ func TestStackClearFunc(t *testing.T) {
 	assert.NoError(t, err)
 }
 
-func TestSystemDefineFunc(t *testing.T) {
+func TestStackDepthFunc(t *testing.T) {
 	// setup
 	c, _ := xCairn("")
-	c.Queue.EnqueueAll([]any{"foo", 123, "end"})
+	c.Stack.PushAll([]int{1, 2})
 
 	// success
-	err := SystemDefineFunc(c)
-	assert.Empty(t, c.Queue.Atoms)
-	assert.NotNil(t, c.Funcs["foo"])
+	err := StackDepthFunc(c)
+	assert.Equal(t, []int{1, 2, 2}, c.Stack.Integers)
 	assert.NoError(t, err)
+}
 
-	// success - function test
-	err = c.Funcs["foo"](c)
-	assert.Equal(t, []int{123}, c.Stack.Integers)
+func TestStackDropFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{1, 2})
+
+	// success
+	err := StackDropFunc(c)
+	assert.Equal(t, []int{1}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// failure - stack is empty
+	c.Stack.Clear()
+	c.Stack.PushAll([]int{})
+	err = StackDropFunc(c)
+	assert.EqualError(t, err, "stack is empty")
+}
+
+func TestStackDrop2Func(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{1, 2, 3})
+
+	// success
+	err := StackDrop2Func(c)
+	assert.Equal(t, []int{1}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// failure - stack is empty
+	c.Stack.Clear()
+	c.Stack.PushAll([]int{1})
+	err = StackDrop2Func(c)
+	assert.EqualError(t, err, "stack is empty")
+}
+
+func TestStackDupFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{1})
+
+	// success
+	err := StackDupFunc(c)
+	assert.Equal(t, []int{1, 1}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// success - registers untouched
+	c.Table.Set(0, 123)
+	err = StackDupFunc(c)
+	assert.Equal(t, 123, c.Table.Get(0))
+	assert.NoError(t, err)
+
+	// failure - stack is empty
+	c.Stack.Clear()
+	c.Stack.PushAll([]int{})
+	err = StackDupFunc(c)
+	assert.EqualError(t, err, "stack is empty")
+}
+
+func TestStackDup2Func(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{1, 2})
+
+	// success
+	err := StackDup2Func(c)
+	assert.Equal(t, []int{1, 2, 1, 2}, c.Stack.Integers)
+	assert.NoError(t, err)
+}
+
+func TestStackNipFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{1, 2})
+
+	// success
+	err := StackNipFunc(c)
+	assert.Equal(t, []int{2}, c.Stack.Integers)
+	assert.NoError(t, err)
+}
+
+func TestStackOverFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{1, 2})
+
+	// success
+	err := StackOverFunc(c)
+	assert.Equal(t, []int{1, 2, 1}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// failure - stack is empty
+	c.Stack.Clear()
+	c.Stack.PushAll([]int{1})
+	err = StackOverFunc(c)
+	assert.EqualError(t, err, "stack is empty")
+}
+
+func TestStackPickFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{1, 2, 3, 2})
+
+	// success
+	err := StackPickFunc(c)
+	assert.Equal(t, []int{1, 2, 3, 1}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// failure - stack is empty
+	c.Stack.Clear()
+	c.Stack.PushAll([]int{1, 1})
+	err = StackPickFunc(c)
+	assert.EqualError(t, err, "stack is empty")
+}
+
+func TestStackRollFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{1, 2, 3, 2})
+
+	// success
+	err := StackRollFunc(c)
+	assert.Equal(t, []int{2, 3, 1}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// failure - stack is empty
+	c.Stack.Clear()
+	c.Stack.PushAll([]int{1, 1})
+	err = StackRollFunc(c)
+	assert.EqualError(t, err, "stack is empty")
+}
+
+func TestStackRotFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{1, 2, 3})
+
+	// success
+	err := StackRotFunc(c)
+	assert.Equal(t, []int{2, 3, 1}, c.Stack.Integers)
+	assert.NoError(t, err)
+}
+
+func TestStackRotBackFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{1, 2, 3})
+
+	// success
+	err := StackRotBackFunc(c)
+	assert.Equal(t, []int{3, 1, 2}, c.Stack.Integers)
+	assert.NoError(t, err)
+}
+
+func TestStackSwapFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{1, 2})
+
+	// success
+	err := StackSwapFunc(c)
+	assert.Equal(t, []int{2, 1}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// failure - stack is empty
+	c.Stack.Clear()
+	c.Stack.PushAll([]int{1})
+	err = StackSwapFunc(c)
+	assert.EqualError(t, err, "stack is empty")
+}
+
+func TestStackTuckFunc(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{1, 2})
+
+	// success
+	err := StackTuckFunc(c)
+	assert.Equal(t, []int{2, 1, 2}, c.Stack.Integers)
 	assert.NoError(t, err)
 }
 
@@ -239,6 +762,12 @@ func TestTableGetFunc(t *testing.T) {
 	err := TableGetFunc(c)
 	assert.Equal(t, []int{123}, c.Stack.Integers)
 	assert.NoError(t, err)
+
+	// failure - table limit
+	c.Stack.Clear()
+	c.Stack.Push(8)
+	err = TableGetFunc(c)
+	assert.ErrorIs(t, err, vm.ErrTable)
 }
 
 func TestTableSetFunc(t *testing.T) {
@@ -0,0 +1,261 @@
+package cairn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+
+	"github.com/wirehaiku/cairn/vm"
+)
+
+// Linter is a static analyser that finds common mistakes in parsed programs and
+// collects the Problems it finds.
+type Linter struct {
+	Defined  map[string]bool
+	Writes   map[string]map[int]bool
+	Problems []Problem
+}
+
+// anyReg is the register key in a write set for a register computed at runtime.
+const anyReg = -1
+
+// NewLinter returns a pointer to a new Linter knowing the builtin functions.
+func NewLinter() *Linter {
+	l := &Linter{Defined: make(map[string]bool), Writes: make(map[string]map[int]bool)}
+	for s := range Funcs {
+		l.Defined[s] = true
+	}
+
+	return l
+}
+
+// LintFile parses a named program string against a Model and lints it, reporting
+// unmatched "end" tokens and other parse errors as Problems.
+func (l *Linter) LintFile(f, s string, m Model) {
+	n, np := 0, len(l.Problems)
+	w := blockWalker{ts: Tokenise(f, s)}
+	for b, ok := w.next(); ok; b, ok = w.next() {
+		if b.depth < 0 && n == 0 {
+			l.report(w.ts[b.index].Pos, "end", "unmatched %q", "end")
+		} else {
+			n += b.depth
+		}
+	}
+
+	b, err := ParseString(f, s, m)
+	var e *vm.Error
+	switch {
+	case err == nil:
+	case len(l.Problems) > np:
+		return
+	case errors.As(err, &e):
+		l.report(e.Pos, "syntax", "%s", e.Err)
+		return
+	default:
+		l.report(vm.Pos{File: f}, "syntax", "%s", err)
+		return
+	}
+
+	l.defs(b)
+	for ws := maps.Clone(l.Writes); ; ws = maps.Clone(l.Writes) {
+		l.writes(b)
+		if maps.EqualFunc(ws, l.Writes, maps.Equal) {
+			break
+		}
+	}
+
+	l.block(b, make(map[int]string))
+}
+
+// WriteProblems writes Problems to a Writer, one per line as text or as JSON objects
+// if js is true.
+func WriteProblems(w io.Writer, ps []Problem, js bool) error {
+	for _, p := range ps {
+		var err error
+		if js {
+			err = json.NewEncoder(w).Encode(struct {
+				File    string `json:"file"`
+				Line    int    `json:"line"`
+				Col     int    `json:"col"`
+				Kind    string `json:"kind"`
+				Message string `json:"message"`
+			}{p.Pos.File, p.Pos.Line, p.Pos.Col, p.Kind, p.Message})
+		} else {
+			_, err = fmt.Fprintf(w, "%s (%s)\n", p, p.Kind)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// block lints a Block, with a map of the registers set earlier to the names of the
+// functions that clobbered them since, or empty strings if none did.
+func (l *Linter) block(b Block, cs map[int]string) {
+	var halt string
+	for i, a := range b {
+		if halt != "" {
+			l.report(position(a), "unreachable", "code after %q is unreachable", halt)
+			halt = ""
+		}
+
+		switch a := a.(type) {
+		case Atom:
+			s, ok := a.Value.(string)
+			if !ok {
+				continue
+			}
+
+			r, lit := register(b, i)
+			switch {
+			case !l.Defined[s]:
+				l.report(a.Pos, "undefined", "function %q is not defined", s)
+			case s == "die" || s == "bye":
+				halt = s
+			case s == "get" && lit && cs[r] != "":
+				l.report(a.Pos, "clobber", "register %d is read after %q clobbers it", r, cs[r])
+			case s == "set" && lit:
+				cs[r] = ""
+			case s != "set":
+				for r := range l.Writes[s] {
+					if _, ok := cs[r]; ok {
+						cs[r] = s
+					}
+				}
+			}
+
+		case *Cond:
+			l.block(a.Body, maps.Clone(cs))
+
+		case *Def:
+			l.block(a.Body, make(map[int]string))
+
+		case *Help:
+			if !l.Defined[a.Name] {
+				l.report(a.Pos, "undefined", "function %q is not defined", a.Name)
+			}
+
+		case *Loop:
+			ws := make(map[int]bool)
+			l.write(a.Body, ws)
+			if !ws[a.Reg] && !ws[anyReg] {
+				l.report(a.Pos, "loop", "register %d is never set in the for body", a.Reg)
+			}
+
+			l.block(a.Body, maps.Clone(cs))
+		}
+	}
+}
+
+// defs records the names of all functions defined in a Block as defined, reporting
+// definitions that shadow builtin functions.
+func (l *Linter) defs(b Block) {
+	for _, a := range b {
+		switch a := a.(type) {
+		case *Cond:
+			l.defs(a.Body)
+		case *Def:
+			if _, ok := Funcs[a.Name]; ok {
+				l.report(a.Pos, "shadow", "function %q shadows a builtin function", a.Name)
+			}
+
+			l.Defined[a.Name] = true
+			l.defs(a.Body)
+		case *Loop:
+			l.defs(a.Body)
+		}
+	}
+}
+
+// report adds a Problem to the Linter.
+func (l *Linter) report(pos vm.Pos, kind, s string, vs ...any) {
+	l.Problems = append(l.Problems, Problem{pos, kind, fmt.Sprintf(s, vs...)})
+}
+
+// write adds the registers set in a Block, directly or by calling functions, to a
+// write set.
+func (l *Linter) write(b Block, ws map[int]bool) {
+	for i, a := range b {
+		switch a := a.(type) {
+		case Atom:
+			s, ok := a.Value.(string)
+			if !ok {
+				continue
+			}
+
+			if s == "set" {
+				if r, lit := register(b, i); lit {
+					ws[r] = true
+				} else {
+					ws[anyReg] = true
+				}
+			}
+
+			maps.Copy(ws, l.Writes[s])
+
+		case *Cond:
+			l.write(a.Body, ws)
+		case *Loop:
+			l.write(a.Body, ws)
+		}
+	}
+}
+
+// writes records the registers set by every function defined in a Block.
+func (l *Linter) writes(b Block) {
+	for _, a := range b {
+		switch a := a.(type) {
+		case *Cond:
+			l.writes(a.Body)
+		case *Def:
+			ws := make(map[int]bool)
+			maps.Copy(ws, l.Writes[a.Name])
+			l.write(a.Body, ws)
+			l.Writes[a.Name] = ws
+			l.writes(a.Body)
+		case *Loop:
+			l.writes(a.Body)
+		}
+	}
+}
+
+// position returns the source position of a parsed atom or node.
+func position(a any) vm.Pos {
+	switch a := a.(type) {
+	case Atom:
+		return a.Pos
+	case *Cond:
+		return a.Pos
+	case *Def:
+		return a.Pos
+	case *Help:
+		return a.Pos
+	case *Loop:
+		return a.Pos
+	case *Test:
+		return a.Pos
+	default:
+		return vm.Pos{}
+	}
+}
+
+// register returns the register of the "get" or "set" call at an index in a Block
+// and true if it is the integer literal before the call.
+func register(b Block, i int) (int, bool) {
+	if i == 0 {
+		return 0, false
+	}
+
+	if a, ok := b[i-1].(Atom); ok {
+		if r, ok := a.Value.(int); ok {
+			return r, true
+		}
+	}
+
+	return 0, false
+}
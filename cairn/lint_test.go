@@ -0,0 +1,126 @@
+package cairn
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wirehaiku/cairn/vm"
+)
+
+func xLint(s string) []string {
+	l := NewLinter()
+	l.LintFile("library", Library, DefaultModel)
+	l.LintFile("", s, DefaultModel)
+
+	var ss []string
+	for _, p := range l.Problems {
+		ss = append(ss, p.Kind+" "+p.String())
+	}
+
+	return ss
+}
+
+func TestNewLinter(t *testing.T) {
+	// success
+	l := NewLinter()
+	assert.True(t, l.Defined["add"])
+	assert.Empty(t, l.Writes)
+	assert.Empty(t, l.Problems)
+}
+
+func TestLinterLintFile(t *testing.T) {
+	// setup
+	l := NewLinter()
+
+	// success - library
+	l.LintFile("library", Library, DefaultModel)
+	assert.True(t, l.Defined["rclr"])
+	assert.Equal(t, map[int]bool{0: true, 1: true}, l.Writes["rclr"])
+	assert.Empty(t, l.Problems)
+
+	// success - no problems
+	ss := xLint("def foo 1 0 set end 3 0 set for 0 0 get 1 - 0 set end foo rclr t?")
+	assert.Empty(t, ss)
+
+	// success - undefined symbols
+	ss = xLint("1 nope def bar 2 end bar")
+	assert.Equal(t, []string{`undefined 1:3: function "nope" is not defined`}, ss)
+
+	// success - shadowed builtins
+	ss = xLint("def dup 1 end")
+	assert.Equal(t, []string{`shadow 1:1: function "dup" shadows a builtin function`}, ss)
+
+	// success - unreachable code
+	ss = xLint("1 ift 0 die 2 3 end 4 bye")
+	assert.Equal(t, []string{`unreachable 1:13: code after "die" is unreachable`}, ss)
+
+	// success - unset loop registers
+	ss = xLint("def dec 0 get 1 - 0 set end for 0 dec end for 1 2 drop end for 2 5 dup set end")
+	assert.Equal(t, []string{`loop 1:43: register 1 is never set in the for body`}, ss)
+
+	// success - clobbered registers
+	ss = xLint("5 0 set rclr 0 get 6 1 set 1 get")
+	assert.Equal(t, []string{`clobber 1:16: register 0 is read after "rclr" clobbers it`}, ss)
+
+	// success - help names
+	ss = xLint("5 0 set help rclr 0 get drop help nope")
+	assert.Equal(t, []string{`undefined 1:30: function "nope" is not defined`}, ss)
+
+	// success - unmatched end
+	ss = xLint("1 end 2 end")
+	assert.Equal(t, []string{`end 1:3: unmatched "end"`, `end 1:9: unmatched "end"`}, ss)
+
+	// success - syntax error
+	ss = xLint("def foo")
+	assert.Equal(t, []string{`syntax 1:1: missing "end"`}, ss)
+}
+
+func TestWriteProblems(t *testing.T) {
+	// setup
+	b := bytes.NewBuffer(nil)
+	ps := []Problem{{vm.Pos{File: "a", Line: 1, Col: 2}, "undefined", "msg"}}
+
+	// success - text
+	err := WriteProblems(b, ps, false)
+	assert.Equal(t, "a:1:2: msg (undefined)\n", b.String())
+	assert.NoError(t, err)
+
+	// success - json
+	b.Reset()
+	err = WriteProblems(b, ps, true)
+	assert.Equal(t, `{"file":"a","line":1,"col":2,"kind":"undefined","message":"msg"}`+"\n", b.String())
+	assert.NoError(t, err)
+}
+
+func TestPosition(t *testing.T) {
+	// setup
+	pos := vm.Pos{Line: 1, Col: 2}
+
+	// success
+	assert.Equal(t, pos, position(Atom{1, pos}))
+	assert.Equal(t, pos, position(&Cond{Pos: pos}))
+	assert.Equal(t, pos, position(&Def{Pos: pos}))
+	assert.Equal(t, pos, position(&Help{Pos: pos}))
+	assert.Equal(t, pos, position(&Loop{Pos: pos}))
+	assert.Equal(t, pos, position(&Test{Pos: pos}))
+	assert.Equal(t, vm.Pos{}, position(1))
+}
+
+func TestRegister(t *testing.T) {
+	// setup
+	b := Block{Atom{"dup", vm.Pos{}}, Atom{3, vm.Pos{}}, Atom{"get", vm.Pos{}}}
+
+	// success
+	r, ok := register(b, 2)
+	assert.Equal(t, 3, r)
+	assert.True(t, ok)
+
+	// failure - not a literal
+	_, ok = register(b, 1)
+	assert.False(t, ok)
+
+	// failure - first atom
+	_, ok = register(b, 0)
+	assert.False(t, ok)
+}
@@ -0,0 +1,109 @@
+package cairn
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// Model is a machine model describing integer words and memory capacity.
+type Model struct {
+	Width    int
+	Signed   bool
+	Trap     bool
+	StackCap int
+	TableCap int
+}
+
+// DefaultModel is the default 8-bit unsigned machine model from the specification.
+var DefaultModel = Model{
+	Width:    8,
+	Signed:   false,
+	Trap:     false,
+	StackCap: 65536,
+	TableCap: 8,
+}
+
+// Fit returns an integer fitted to the Model's word, wrapping or trapping on overflow.
+func (m Model) Fit(i int) (int, error) {
+	lo, hi := m.Range()
+	if i >= lo && i <= hi {
+		return i, nil
+	}
+
+	if m.Trap {
+		return 0, fmt.Errorf("integer %d overflows %s word", i, m)
+	}
+
+	return m.Wrap(i), nil
+}
+
+// Range returns the smallest and largest integers in the Model's word.
+func (m Model) Range() (int, int) {
+	switch {
+	case m.Width >= 64 && m.Signed:
+		return math.MinInt64, math.MaxInt64
+	case m.Width >= 64:
+		return 0, math.MaxInt64
+	case m.Signed:
+		return -1 << (m.Width - 1), 1<<(m.Width-1) - 1
+	default:
+		return 0, 1<<m.Width - 1
+	}
+}
+
+// Rotate returns an integer's bits rotated left within the Model's word, or right
+// for negative counts.
+func (m Model) Rotate(i, n int) int {
+	if m.Width >= 64 {
+		return m.Wrap(int(bits.RotateLeft64(uint64(i), n)))
+	}
+
+	w := m.Width
+	u := uint64(i) & (1<<w - 1)
+	n = ((n % w) + w) % w
+	return m.Wrap(int(u<<n | u>>(w-n)))
+}
+
+// String returns the Model's word as a string.
+func (m Model) String() string {
+	if m.Signed {
+		return fmt.Sprintf("signed %d-bit", m.Width)
+	}
+
+	return fmt.Sprintf("unsigned %d-bit", m.Width)
+}
+
+// Wrap returns an integer wrapped to the Model's word. Unsigned 64-bit words are
+// limited to 63 bits and 64-bit words always wrap natively.
+func (m Model) Wrap(i int) int {
+	switch {
+	case m.Width >= 64 && m.Signed:
+		return i
+	case m.Width >= 64:
+		return i & math.MaxInt64
+	case m.Signed:
+		i &= 1<<m.Width - 1
+		if i >= 1<<(m.Width-1) {
+			i -= 1 << m.Width
+		}
+
+		return i
+	default:
+		return i & (1<<m.Width - 1)
+	}
+}
+
+// Validate returns an error if the Model is invalid.
+func (m Model) Validate() error {
+	switch {
+	case m.Width != 8 && m.Width != 16 && m.Width != 32 && m.Width != 64:
+		return fmt.Errorf("word width %d is not 8, 16, 32 or 64", m.Width)
+	case m.StackCap < 0:
+		return fmt.Errorf("stack capacity %d is negative", m.StackCap)
+	case m.TableCap < 0:
+		return fmt.Errorf("table capacity %d is negative", m.TableCap)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,127 @@
+package cairn
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelFit(t *testing.T) {
+	// setup
+	m := DefaultModel
+
+	// success - in range
+	i, err := m.Fit(123)
+	assert.Equal(t, 123, i)
+	assert.NoError(t, err)
+
+	// success - unsigned wrap
+	i, err = m.Fit(-1)
+	assert.Equal(t, 255, i)
+	assert.NoError(t, err)
+
+	i, err = m.Fit(256)
+	assert.Equal(t, 0, i)
+	assert.NoError(t, err)
+
+	// success - signed wrap
+	m.Signed = true
+	i, err = m.Fit(128)
+	assert.Equal(t, -128, i)
+	assert.NoError(t, err)
+
+	i, err = m.Fit(-129)
+	assert.Equal(t, 127, i)
+	assert.NoError(t, err)
+
+	// success - unsigned 64-bit wrap
+	m = Model{Width: 64}
+	i, err = m.Fit(-1)
+	assert.Equal(t, math.MaxInt64, i)
+	assert.NoError(t, err)
+
+	// failure - trapped overflow
+	m = DefaultModel
+	m.Trap = true
+	i, err = m.Fit(256)
+	assert.Zero(t, i)
+	assert.EqualError(t, err, "integer 256 overflows unsigned 8-bit word")
+}
+
+func TestModelRange(t *testing.T) {
+	// success - unsigned
+	lo, hi := Model{Width: 16}.Range()
+	assert.Equal(t, 0, lo)
+	assert.Equal(t, 65535, hi)
+
+	// success - signed
+	lo, hi = Model{Width: 16, Signed: true}.Range()
+	assert.Equal(t, -32768, lo)
+	assert.Equal(t, 32767, hi)
+
+	// success - signed 64-bit
+	lo, hi = Model{Width: 64, Signed: true}.Range()
+	assert.Equal(t, math.MinInt64, lo)
+	assert.Equal(t, math.MaxInt64, hi)
+}
+
+func TestModelRotate(t *testing.T) {
+	// setup
+	m := DefaultModel
+
+	// success - left
+	i := m.Rotate(0b10000001, 1)
+	assert.Equal(t, 0b00000011, i)
+
+	// success - right
+	i = m.Rotate(0b10000001, -1)
+	assert.Equal(t, 0b11000000, i)
+
+	// success - signed
+	m.Signed = true
+	i = m.Rotate(0b01000000, 1)
+	assert.Equal(t, -128, i)
+
+	// success - 64-bit
+	i = Model{Width: 64, Signed: true}.Rotate(1, -1)
+	assert.Equal(t, math.MinInt64, i)
+}
+
+func TestModelString(t *testing.T) {
+	// success - unsigned
+	s := Model{Width: 8}.String()
+	assert.Equal(t, "unsigned 8-bit", s)
+
+	// success - signed
+	s = Model{Width: 32, Signed: true}.String()
+	assert.Equal(t, "signed 32-bit", s)
+}
+
+func TestModelWrap(t *testing.T) {
+	// success - unsigned
+	i := DefaultModel.Wrap(-1)
+	assert.Equal(t, 255, i)
+
+	// success - signed
+	i = Model{Width: 8, Signed: true}.Wrap(255)
+	assert.Equal(t, -1, i)
+
+	// success - trap ignored
+	i = Model{Width: 8, Trap: true}.Wrap(256)
+	assert.Equal(t, 0, i)
+}
+
+func TestModelValidate(t *testing.T) {
+	// success
+	err := DefaultModel.Validate()
+	assert.NoError(t, err)
+
+	// failure - invalid width
+	err = Model{Width: 12}.Validate()
+	assert.EqualError(t, err, "word width 12 is not 8, 16, 32 or 64")
+
+	// failure - negative capacity
+	err = Model{Width: 8, StackCap: -1}.Validate()
+	assert.EqualError(t, err, "stack capacity -1 is negative")
+}
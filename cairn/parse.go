@@ -1,37 +1,507 @@
 package cairn
 
 import (
+	"errors"
+	"fmt"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/wirehaiku/cairn/vm"
 )
 
-// Atomise returns an atom from a token string.
-func Atomise(s string) any {
-	if i, err := strconv.ParseInt(s, 10, 0); err == nil {
-		return int(i)
+// Atom is a parsed integer or symbol with a source position.
+type Atom struct {
+	Value any
+	Pos   vm.Pos
+}
+
+// Block is a parsed sequence of atoms and nodes.
+type Block []any
+
+// Cond is a parsed conditional node, evaluated if a popped integer matches Want.
+type Cond struct {
+	Want bool
+	Body Block
+	Pos  vm.Pos
+}
+
+// Def is a parsed function definition node, with the comment following its name.
+type Def struct {
+	Name string
+	Doc  string
+	Body Block
+	Pos  vm.Pos
+}
+
+// Help is a parsed help node, writing the documentation of the function Name.
+type Help struct {
+	Name string
+	Pos  vm.Pos
+}
+
+// Loop is a parsed loop node, repeated until register Reg is zero.
+type Loop struct {
+	Reg  int
+	Body Block
+	Pos  vm.Pos
+}
+
+// Quote is a parsed string literal, pushed as its bytes followed by its length.
+type Quote string
+
+// Test is a parsed assertion node, failing with its code text if a popped integer is zero.
+type Test struct {
+	Text string
+	Pos  vm.Pos
+}
+
+// Token is a program token with a source position.
+type Token struct {
+	Text string
+	Pos  vm.Pos
+}
+
+// blockToken is a keyword token found by a blockWalker, with the change it makes to
+// the block depth and the index of its operand token, 0 if it takes none or -1 if it
+// is missing.
+type blockToken struct {
+	key     string
+	index   int
+	operand int
+	depth   int
+}
+
+// blockWalker walks the keyword tokens of a token slice that open or close blocks or
+// take an operand, skipping comments and operand tokens.
+type blockWalker struct {
+	ts []Token
+	i  int
+}
+
+// Atomise returns an atom from a token string, with symbols in lower case.
+func Atomise(s string) (any, error) {
+	switch {
+	case strings.HasPrefix(s, "'"):
+		s2, err := strconv.Unquote(s)
+		if err != nil || utf8.RuneCountInString(s2) != 1 {
+			return nil, fmt.Errorf("invalid character literal %s", s)
+		}
+
+		if len(s2) == 1 {
+			return int(s2[0]), nil
+		}
+
+		r, _ := utf8.DecodeRuneInString(s2)
+		return int(r), nil
+
+	case strings.HasPrefix(s, `"`):
+		s2, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string literal %s", s)
+		}
+
+		return Quote(s2), nil
+	}
+
+	if i, ok, err := integer(s); err != nil {
+		return nil, err
+	} else if ok {
+		return i, nil
 	}
 
-	return s
+	return strings.ToLower(s), nil
 }
 
 // AtomiseAll returns an atom slice from a token slice.
-func AtomiseAll(ss []string) []any {
+func AtomiseAll(ss []string) ([]any, error) {
 	var as []any
 	for _, s := range ss {
-		as = append(as, Atomise(s))
+		a, err := Atomise(s)
+		if err != nil {
+			return nil, err
+		}
+
+		as = append(as, a)
 	}
 
-	return as
+	return as, nil
 }
 
-// Tokenise returns a token slice from a program string.
-func Tokenise(s string) []string {
-	var ss []string
+// Parse returns a Block from a token slice that may include comments, with literals
+// checked against a Model.
+func Parse(ts []Token, m Model) (Block, error) {
+	q := NewQueue()
+	for _, t := range ts {
+		q.Enqueue(t)
+	}
+
+	b, err := parseBlock(q, m)
+	if err != nil {
+		return nil, err
+	}
+
+	if !q.Empty() {
+		t := q.Atoms[0].(Token)
+		return nil, &vm.Error{Err: fmt.Errorf("unexpected %q", "end"), Pos: t.Pos}
+	}
+
+	return b, nil
+}
+
+// ParseString returns a Block from a named program string, with literals checked
+// against a Model.
+func ParseString(f, s string, m Model) (Block, error) {
+	return Parse(TokeniseComments(f, s), m)
+}
+
+// Tokenise returns a token slice from a named program string.
+func Tokenise(f, s string) []Token {
+	var ts []Token
+	for _, t := range TokeniseComments(f, s) {
+		if !strings.HasPrefix(t.Text, "//") {
+			ts = append(ts, t)
+		}
+	}
+
+	return ts
+}
+
+// TokeniseComments returns a token slice from a named program string, including
+// comments as tokens starting with "//".
+func TokeniseComments(f, s string) []Token {
+	var ts []Token
+
+	for l, s := range strings.Split(s, "\n") {
+		rs := []rune(s)
+
+		for i := 0; i < len(rs); i++ {
+			if unicode.IsSpace(rs[i]) {
+				continue
+			}
+
+			if comment(rs, i) {
+				s := strings.TrimRightFunc(string(rs[i:]), unicode.IsSpace)
+				ts = append(ts, Token{s, vm.Pos{File: f, Line: l + 1, Col: i + 1}})
+				break
+			}
+
+			j := i + 1
+			if rs[i] == '"' || rs[i] == '\'' {
+				for j < len(rs) && rs[j] != rs[i] {
+					if rs[j] == '\\' {
+						j++
+					}
+
+					j++
+				}
+
+				j = min(j+1, len(rs))
+			} else {
+				for j < len(rs) && !unicode.IsSpace(rs[j]) && !comment(rs, j) {
+					j++
+				}
+			}
+
+			ts = append(ts, Token{string(rs[i:j]), vm.Pos{File: f, Line: l + 1, Col: i + 1}})
+			i = j - 1
+		}
+	}
+
+	return ts
+}
+
+// next returns the next keyword token from the blockWalker, or false if there are
+// no more.
+func (w *blockWalker) next() (blockToken, bool) {
+	for ; w.i < len(w.ts); w.i++ {
+		b := blockToken{key: keyword(w.ts[w.i]), index: w.i}
+		switch b.key {
+		case "def", "for":
+			b.depth, b.operand = 1, operand(w.ts, w.i)
+		case "help":
+			b.operand = operand(w.ts, w.i)
+		case "ift", "iff", "tst":
+			b.depth = 1
+		case "end":
+			b.depth = -1
+		default:
+			continue
+		}
+
+		w.i = max(w.i, b.operand) + 1
+		return b, true
+	}
 
-	for _, s := range strings.Split(s, "\n") {
-		s = strings.SplitN(s, "//", 2)[0]
-		ss = append(ss, strings.Fields(s)...)
+	return blockToken{}, false
+}
+
+// comment returns true if a comment starts at an index in a rune slice.
+func comment(rs []rune, i int) bool {
+	return i+1 < len(rs) && rs[i] == '/' && rs[i+1] == '/'
+}
+
+// integer returns an integer from a decimal, hexadecimal, octal or binary token
+// string, and false if the string is not an integer.
+func integer(s string) (int, bool, error) {
+	t := strings.TrimPrefix(s, "-")
+	base := 10
+
+	if len(t) > 2 && t[0] == '0' {
+		switch t[1] {
+		case 'x', 'X':
+			base, t = 16, t[2:]
+		case 'o', 'O':
+			base, t = 8, t[2:]
+		case 'b', 'B':
+			base, t = 2, t[2:]
+		}
+	}
+
+	if t == "" || t[0] == '_' || t[len(t)-1] == '_' || strings.Contains(t, "__") {
+		return 0, false, nil
+	}
+
+	if strings.HasPrefix(s, "-") {
+		t = "-" + t
+	}
+
+	i, err := strconv.ParseInt(strings.ReplaceAll(t, "_", ""), base, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, true, fmt.Errorf("integer %s is out of range", s)
+	}
+
+	return int(i), err == nil, nil
+}
+
+// keyword returns a Token's text in lower case for matching against keywords.
+func keyword(t Token) string {
+	return strings.ToLower(t.Text)
+}
+
+// operand returns the index of the first non-comment token after an index in a token
+// slice, or -1 if there is none.
+func operand(ts []Token, i int) int {
+	for i++; i < len(ts); i++ {
+		if !strings.HasPrefix(ts[i].Text, "//") {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// parseAtom returns an Atom from a Token, with literals checked against a Model.
+func parseAtom(t Token, m Model) (Atom, error) {
+	a, err := Atomise(t.Text)
+	if err == nil {
+		err = parseRange(a, m)
+	}
+
+	if err != nil {
+		return Atom{}, &vm.Error{Err: err, Pos: t.Pos}
+	}
+
+	return Atom{a, t.Pos}, nil
+}
+
+// parseBlock returns a Block from a Queue, stopping before an unmatched "end" token.
+func parseBlock(q *Queue, m Model) (Block, error) {
+	var b Block
+
+	for !q.Empty() {
+		t := q.Atoms[0].(Token)
+		if keyword(t) == "end" {
+			break
+		}
+
+		q.Dequeue()
+		if strings.HasPrefix(t.Text, "//") {
+			continue
+		}
+
+		var a any
+		var err error
+
+		switch keyword(t) {
+		case "def":
+			a, err = parseDef(q, t, m)
+		case "ift", "iff":
+			a, err = parseCond(q, t, m)
+		case "for":
+			a, err = parseLoop(q, t, m)
+		case "help":
+			a, err = parseHelp(q, t, m)
+		case "tst":
+			a, err = parseTest(q, t, m)
+		default:
+			a, err = parseAtom(t, m)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		b = append(b, a)
+	}
+
+	return b, nil
+}
+
+// parseBody returns a Block from a Queue and removes the closing "end" token.
+func parseBody(q *Queue, t Token, m Model) (Block, error) {
+	b, err := parseBlock(q, m)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.Empty() {
+		return nil, &vm.Error{Err: fmt.Errorf("missing %q", "end"), Pos: t.Pos}
+	}
+
+	q.Dequeue()
+	return b, nil
+}
+
+// parseCond returns a Cond from a Queue.
+func parseCond(q *Queue, t Token, m Model) (*Cond, error) {
+	b, err := parseBody(q, t, m)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cond{keyword(t) == "ift", b, t.Pos}, nil
+}
+
+// parseDef returns a Def from a Queue.
+func parseDef(q *Queue, t Token, m Model) (*Def, error) {
+	s, err := parseName(q, t, m)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc string
+	if !q.Empty() && strings.HasPrefix(q.Atoms[0].(Token).Text, "//") {
+		doc = strings.TrimSpace(strings.TrimPrefix(q.Atoms[0].(Token).Text, "//"))
+	}
+
+	b, err := parseBody(q, t, m)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Def{s, doc, b, t.Pos}, nil
+}
+
+// parseHelp returns a Help from a Queue.
+func parseHelp(q *Queue, t Token, m Model) (*Help, error) {
+	s, err := parseName(q, t, m)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Help{s, t.Pos}, nil
+}
+
+// parseLoop returns a Loop from a Queue.
+func parseLoop(q *Queue, t Token, m Model) (*Loop, error) {
+	n, err := parseOperand(q, t)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := parseAtom(n, m)
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := ToInteger(a.Value)
+	if err != nil {
+		return nil, &vm.Error{Err: err, Pos: n.Pos}
+	}
+
+	b, err := parseBody(q, t, m)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Loop{i, b, t.Pos}, nil
+}
+
+// parseName returns the function name following a keyword Token from a Queue.
+func parseName(q *Queue, t Token, m Model) (string, error) {
+	n, err := parseOperand(q, t)
+	if err != nil {
+		return "", err
+	}
+
+	a, err := parseAtom(n, m)
+	if err != nil {
+		return "", err
+	}
+
+	s, err := ToSymbol(a.Value)
+	if err != nil {
+		return "", &vm.Error{Err: err, Pos: n.Pos}
+	}
+
+	return s, nil
+}
+
+// parseOperand returns the non-comment Token following a keyword Token from a Queue.
+func parseOperand(q *Queue, t Token) (Token, error) {
+	for {
+		a, err := q.Dequeue()
+		if err != nil {
+			return Token{}, &vm.Error{Err: err, Pos: t.Pos}
+		}
+
+		if n := a.(Token); !strings.HasPrefix(n.Text, "//") {
+			return n, nil
+		}
+	}
+}
+
+// parseRange returns an error if a literal atom, or any byte of a string literal, is
+// out of range for a Model.
+func parseRange(a any, m Model) error {
+	lo, hi := m.Range()
+
+	switch a := a.(type) {
+	case int:
+		if a < lo || a > hi {
+			return fmt.Errorf("integer %d is out of range for %s word", a, m)
+		}
+
+	case Quote:
+		if len(a) > hi {
+			return fmt.Errorf("string length %d is out of range for %s word", len(a), m)
+		}
+
+		for _, b := range []byte(a) {
+			if int(b) < lo || int(b) > hi {
+				return fmt.Errorf("string byte %d is out of range for %s word", b, m)
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseTest returns a Test from a Queue, using the text of its body tokens.
+func parseTest(q *Queue, t Token, m Model) (*Test, error) {
+	as := q.Atoms
+	if _, err := parseBody(q, t, m); err != nil {
+		return nil, err
+	}
+
+	var ss []string
+	for _, a := range as[:len(as)-len(q.Atoms)-1] {
+		if s := a.(Token).Text; !strings.HasPrefix(s, "//") {
+			ss = append(ss, s)
+		}
 	}
 
-	return ss
+	return &Test{strings.Join(ss, " "), t.Pos}, nil
 }
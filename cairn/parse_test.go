@@ -1,35 +1,233 @@
 package cairn
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/wirehaiku/cairn/vm"
 )
 
 func TestAtomise(t *testing.T) {
 	// success - integer
-	a := Atomise("123")
+	a, err := Atomise("123")
 	assert.Equal(t, 123, a)
+	assert.NoError(t, err)
 
 	// success - symbol
-	a = Atomise("foo")
+	a, err = Atomise("foo")
 	assert.Equal(t, "foo", a)
+	assert.NoError(t, err)
+
+	// success - upper-case symbol
+	a, err = Atomise("FOO")
+	assert.Equal(t, "foo", a)
+	assert.NoError(t, err)
+
+	// success - prefixed integers
+	for s, i := range map[string]int{
+		"0x1F": 31, "0o17": 15, "0b1010": 10, "1_000": 1000, "0b1111_0000": 240, "-0x10": -16,
+	} {
+		a, err = Atomise(s)
+		assert.Equal(t, i, a, s)
+		assert.NoError(t, err, s)
+	}
+
+	// success - integer-like symbols
+	for _, s := range []string{"-", "-rot", "2dup", "0x", "1__0", "_1"} {
+		a, err = Atomise(s)
+		assert.Equal(t, s, a, s)
+		assert.NoError(t, err, s)
+	}
+
+	// success - character literal
+	a, err = Atomise(`'\n'`)
+	assert.Equal(t, 10, a)
+	assert.NoError(t, err)
+
+	// success - string literal
+	a, err = Atomise(`"a b\n"`)
+	assert.Equal(t, Quote("a b\n"), a)
+	assert.NoError(t, err)
+
+	// failure - integer out of range
+	a, err = Atomise("0xFFFFFFFFFFFFFFFFFF")
+	assert.Nil(t, a)
+	assert.EqualError(t, err, "integer 0xFFFFFFFFFFFFFFFFFF is out of range")
+
+	// failure - invalid character literal
+	a, err = Atomise(`'ab'`)
+	assert.Nil(t, a)
+	assert.EqualError(t, err, `invalid character literal 'ab'`)
+
+	// failure - invalid string literal
+	a, err = Atomise(`"ab`)
+	assert.Nil(t, a)
+	assert.EqualError(t, err, `invalid string literal "ab`)
 }
 
 func TestAtomiseAll(t *testing.T) {
 	// success
-	as := AtomiseAll([]string{"123", "foo"})
+	as, err := AtomiseAll([]string{"123", "foo"})
 	assert.Equal(t, []any{123, "foo"}, as)
+	assert.NoError(t, err)
+
+	// failure - invalid literal
+	as, err = AtomiseAll([]string{`"foo`})
+	assert.Nil(t, as)
+	assert.EqualError(t, err, `invalid string literal "foo`)
+}
+
+func TestParse(t *testing.T) {
+	// setup
+	p := func(l, c int) vm.Pos { return vm.Pos{File: "", Line: l, Col: c} }
+
+	// success
+	b, err := Parse(Tokenise("", "1 def foo 0 ift 2 end end\nfor 0 3 end"), DefaultModel)
+	assert.Equal(t, Block{
+		Atom{1, p(1, 1)},
+		&Def{"foo", "", Block{
+			Atom{0, p(1, 11)},
+			&Cond{true, Block{Atom{2, p(1, 17)}}, p(1, 13)},
+		}, p(1, 3)},
+		&Loop{0, Block{Atom{3, p(2, 7)}}, p(2, 1)},
+	}, b)
+	assert.NoError(t, err)
+
+	// success - comments
+	b, err = Parse(TokeniseComments("", "def // x\nfoo // (a -- b) foo.\n1 // y\nend\ntst 1 // z\nend"),
+		DefaultModel)
+	assert.Equal(t, Block{
+		&Def{"foo", "(a -- b) foo.", Block{Atom{1, p(3, 1)}}, p(1, 1)},
+		&Test{"1", p(5, 1)},
+	}, b)
+	assert.NoError(t, err)
+
+	// success - help
+	b, err = Parse(Tokenise("", "HELP END"), DefaultModel)
+	assert.Equal(t, Block{&Help{"end", p(1, 1)}}, b)
+	assert.NoError(t, err)
+
+	// success - test
+	b, err = Parse(Tokenise("", "TST 1 IFT 2 END END"), DefaultModel)
+	assert.Equal(t, Block{&Test{"1 IFT 2 END", p(1, 1)}}, b)
+	assert.NoError(t, err)
+
+	// failure - invalid literal
+	b, err = Parse(Tokenise("", "1 'ab'"), DefaultModel)
+	assert.Nil(t, b)
+	assert.EqualError(t, err, `1:3: invalid character literal 'ab'`)
+
+	// success - signed literal
+	b, err = Parse(Tokenise("", "-128"), Model{Width: 8, Signed: true})
+	assert.Equal(t, Block{Atom{-128, p(1, 1)}}, b)
+	assert.NoError(t, err)
+
+	// failure - negative literal
+	b, err = Parse(Tokenise("", "1 -1"), DefaultModel)
+	assert.Nil(t, b)
+	assert.EqualError(t, err, "1:3: integer -1 is out of range for unsigned 8-bit word")
+
+	// failure - large literal
+	b, err = Parse(Tokenise("", "0x100"), DefaultModel)
+	assert.Nil(t, b)
+	assert.EqualError(t, err, "1:1: integer 256 is out of range for unsigned 8-bit word")
+
+	// failure - large string literal
+	b, err = Parse(Tokenise("", `"`+strings.Repeat("a", 256)+`"`), DefaultModel)
+	assert.Nil(t, b)
+	assert.EqualError(t, err, "1:1: string length 256 is out of range for unsigned 8-bit word")
+
+	// failure - large string byte
+	b, err = Parse(Tokenise("", `"a\xff"`), Model{Width: 8, Signed: true})
+	assert.Nil(t, b)
+	assert.EqualError(t, err, "1:1: string byte 255 is out of range for signed 8-bit word")
+
+	// failure - large character literal
+	b, err = Parse(Tokenise("", `'\xff'`), Model{Width: 8, Signed: true})
+	assert.Nil(t, b)
+	assert.EqualError(t, err, "1:1: integer 255 is out of range for signed 8-bit word")
+
+	// failure - missing help name
+	b, err = Parse(Tokenise("", "1 help"), DefaultModel)
+	assert.Nil(t, b)
+	assert.EqualError(t, err, "1:3: queue is empty")
+
+	// failure - integer help name
+	b, err = Parse(Tokenise("", "help 1"), DefaultModel)
+	assert.Nil(t, b)
+	assert.ErrorContains(t, err, "1:6: ")
+
+	// failure - missing end
+	b, err = Parse(Tokenise("", "iff 1"), DefaultModel)
+	assert.Nil(t, b)
+	assert.EqualError(t, err, `1:1: missing "end"`)
+
+	// failure - unexpected end
+	b, err = Parse(Tokenise("", "1 end"), DefaultModel)
+	assert.Nil(t, b)
+	assert.EqualError(t, err, `1:3: unexpected "end"`)
+
+	// failure - non-symbol definition
+	b, err = Parse(Tokenise("", "def 1 end"), DefaultModel)
+	assert.Nil(t, b)
+	assert.EqualError(t, err, `1:5: non-symbol "1" provided`)
+
+	// failure - missing operand
+	b, err = Parse(Tokenise("", "for"), DefaultModel)
+	assert.Nil(t, b)
+	assert.EqualError(t, err, `1:1: queue is empty`)
+}
+
+func TestParseString(t *testing.T) {
+	// success
+	b, err := ParseString("a.txt", "1", DefaultModel)
+	assert.Equal(t, Block{Atom{1, vm.Pos{File: "a.txt", Line: 1, Col: 1}}}, b)
+	assert.NoError(t, err)
 }
 
 func TestTokenise(t *testing.T) {
 	// setup
-	s := `
-		// comment
-		123 foo // comment
-		// comment
-	`
-
-	ss := Tokenise(s)
-	assert.Equal(t, []string{"123", "foo"}, ss)
+	s := "// comment\n\t123 foo // comment\n// comment\n"
+
+	// success
+	ts := Tokenise("a.txt", s)
+	assert.Equal(t, []Token{
+		{"123", vm.Pos{File: "a.txt", Line: 2, Col: 2}},
+		{"foo", vm.Pos{File: "a.txt", Line: 2, Col: 6}},
+	}, ts)
+
+	// success - literals
+	ts = Tokenise("", `'a' ' ' "b // \" c"//d`)
+	assert.Equal(t, []Token{
+		{`'a'`, vm.Pos{File: "", Line: 1, Col: 1}},
+		{`' '`, vm.Pos{File: "", Line: 1, Col: 5}},
+		{`"b // \" c"`, vm.Pos{File: "", Line: 1, Col: 9}},
+	}, ts)
+}
+
+func TestTokeniseComments(t *testing.T) {
+	// success
+	ts := TokeniseComments("", "// a \n1 //b")
+	assert.Equal(t, []Token{
+		{"// a", vm.Pos{File: "", Line: 1, Col: 1}},
+		{"1", vm.Pos{File: "", Line: 2, Col: 1}},
+		{"//b", vm.Pos{File: "", Line: 2, Col: 3}},
+	}, ts)
+}
+
+func TestBlockWalker(t *testing.T) {
+	// setup
+	w := blockWalker{ts: TokeniseComments("", "def // c\nfoo ift help end end end for")}
+
+	// success
+	var bs []blockToken
+	for b, ok := w.next(); ok; b, ok = w.next() {
+		bs = append(bs, b)
+	}
+
+	assert.Equal(t, []blockToken{
+		{"def", 0, 2, 1}, {"ift", 3, 0, 1}, {"help", 4, 5, 0},
+		{"end", 6, 0, -1}, {"end", 7, 0, -1}, {"for", 8, -1, 1},
+	}, bs)
 }
@@ -0,0 +1,300 @@
+package cairn
+
+import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/wirehaiku/cairn/vm"
+)
+
+// Profiler is a vm.Hook that counts calls, instructions and wall time for each
+// function a Cairn's Machine runs.
+type Profiler struct {
+	Cairn   *Cairn
+	Stats   map[string]*Stat
+	Samples map[string]*Sample
+	Start   time.Time
+	calls   []call
+}
+
+// Sample is the exclusive cost of a chain of function calls, outermost first.
+type Sample struct {
+	Names  []string
+	Calls  int
+	Instrs int
+	Time   time.Duration
+}
+
+// Stat is the total cost of a single function.
+type Stat struct {
+	Name      string
+	Calls     int
+	Instrs    int
+	Inclusive time.Duration
+	Exclusive time.Duration
+}
+
+// call is a function call in progress on a Profiler.
+type call struct {
+	name  string
+	key   string
+	start time.Time
+	child time.Duration
+}
+
+// TopName is the function name a Profiler gives to top-level code.
+const TopName = "(top)"
+
+// NewProfiler returns a pointer to a new Profiler for a Cairn, attached to its
+// Machine and timing from now.
+func NewProfiler(c *Cairn) *Profiler {
+	p := &Profiler{
+		Cairn:   c,
+		Stats:   make(map[string]*Stat),
+		Samples: make(map[string]*Sample),
+		Start:   time.Now(),
+	}
+
+	p.enter(TopName, p.Start)
+	c.Machine.Hooks = append(c.Machine.Hooks, p)
+	return p
+}
+
+// After finishes timing a function call.
+func (p *Profiler) After(f *vm.Frame, in vm.Instr) error {
+	if in.Op == vm.Call || in.Op == vm.User {
+		p.exit(time.Now())
+	}
+
+	return nil
+}
+
+// Before counts an instruction and starts timing a function call.
+func (p *Profiler) Before(f *vm.Frame, in vm.Instr) error {
+	c := p.calls[len(p.calls)-1]
+	p.Stats[c.name].Instrs++
+	p.Samples[c.key].Instrs++
+
+	if in.Op == vm.Call || in.Op == vm.User {
+		p.enter(p.Cairn.Symbols.Name(in.Arg), time.Now())
+	}
+
+	return nil
+}
+
+// Sorted returns the Profiler's Stats sorted by exclusive time, then by name.
+func (p *Profiler) Sorted() []*Stat {
+	var ss []*Stat
+	for _, s := range p.Stats {
+		ss = append(ss, s)
+	}
+
+	slices.SortFunc(ss, func(a, b *Stat) int {
+		if c := cmp.Compare(b.Exclusive, a.Exclusive); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return ss
+}
+
+// Stop finishes timing all function calls in progress, including top-level code.
+func (p *Profiler) Stop() {
+	t := time.Now()
+	for len(p.calls) != 0 {
+		p.exit(t)
+	}
+}
+
+// WriteProfile writes the Profiler's Samples to a Writer as a gzipped pprof profile.
+func (p *Profiler) WriteProfile(w io.Writer) error {
+	var b pbuf
+	ss := []string{""}
+	index := func(s string) int {
+		if i := slices.Index(ss, s); i >= 0 {
+			return i
+		}
+
+		ss = append(ss, s)
+		return len(ss) - 1
+	}
+
+	for _, vt := range [][2]string{{"calls", "count"}, {"instructions", "count"}, {"time", "nanoseconds"}} {
+		var m pbuf
+		m.int(1, index(vt[0]))
+		m.int(2, index(vt[1]))
+		b.bytes(1, m.Bytes())
+	}
+
+	var keys []string
+	for k := range p.Samples {
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+
+	var fs []string
+	for _, k := range keys {
+		s := p.Samples[k]
+
+		var ids []int
+		for i := len(s.Names) - 1; i >= 0; i-- {
+			id := slices.Index(fs, s.Names[i]) + 1
+			if id == 0 {
+				fs = append(fs, s.Names[i])
+				id = len(fs)
+			}
+
+			ids = append(ids, id)
+		}
+
+		var m pbuf
+		m.ints(1, ids)
+		m.ints(2, []int{s.Calls, s.Instrs, int(s.Time)})
+		b.bytes(2, m.Bytes())
+	}
+
+	for i := range fs {
+		var l, m pbuf
+		l.int(1, i+1)
+		m.int(1, i+1)
+		m.bytes(4, l.Bytes())
+		b.bytes(4, m.Bytes())
+	}
+
+	for i, f := range fs {
+		var m pbuf
+		m.int(1, i+1)
+		m.int(2, index(f))
+		m.int(3, index(f))
+		b.bytes(5, m.Bytes())
+	}
+
+	b.int(9, int(p.Start.UnixNano()))
+	b.int(10, int(p.total()))
+
+	var m pbuf
+	m.int(1, index("time"))
+	m.int(2, index("nanoseconds"))
+	b.bytes(11, m.Bytes())
+
+	for _, s := range ss {
+		b.bytes(6, []byte(s))
+	}
+
+	z := gzip.NewWriter(w)
+	if _, err := z.Write(b.Bytes()); err != nil {
+		return err
+	}
+
+	return z.Close()
+}
+
+// WriteReport writes the Profiler's sorted Stats to a Writer as a text table.
+func (p *Profiler) WriteReport(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "%10s %10s %12s %12s  %s\n",
+		"calls", "instrs", "inclusive", "exclusive", "function"); err != nil {
+		return err
+	}
+
+	for _, s := range p.Sorted() {
+		if _, err := fmt.Fprintf(w, "%10d %10d %12s %12s  %s\n",
+			s.Calls, s.Instrs, s.Inclusive, s.Exclusive, s.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// enter starts timing a function call at a time.
+func (p *Profiler) enter(s string, t time.Time) {
+	k, ns := s, []string{s}
+	if len(p.calls) != 0 {
+		c := p.calls[len(p.calls)-1]
+		k = c.key + "\x00" + s
+		ns = append(slices.Clone(p.Samples[c.key].Names), s)
+	}
+
+	if _, ok := p.Stats[s]; !ok {
+		p.Stats[s] = &Stat{Name: s}
+	}
+
+	if _, ok := p.Samples[k]; !ok {
+		p.Samples[k] = &Sample{Names: ns}
+	}
+
+	p.Stats[s].Calls++
+	p.Samples[k].Calls++
+	p.calls = append(p.calls, call{s, k, t, 0})
+}
+
+// exit finishes timing the innermost function call at a time.
+func (p *Profiler) exit(t time.Time) {
+	c := p.calls[len(p.calls)-1]
+	p.calls = p.calls[:len(p.calls)-1]
+
+	d := t.Sub(c.start)
+	p.Stats[c.name].Exclusive += d - c.child
+	p.Samples[c.key].Time += d - c.child
+
+	if !slices.ContainsFunc(p.calls, func(c2 call) bool { return c2.name == c.name }) {
+		p.Stats[c.name].Inclusive += d
+	}
+
+	if len(p.calls) != 0 {
+		p.calls[len(p.calls)-1].child += d
+	}
+}
+
+// total returns the Profiler's total time, or the time since it started if top-level
+// code is still in progress.
+func (p *Profiler) total() time.Duration {
+	if len(p.calls) != 0 {
+		return time.Since(p.Start)
+	}
+
+	return p.Stats[TopName].Inclusive
+}
+
+// pbuf is a minimal protocol buffer encoder.
+type pbuf struct {
+	bytes.Buffer
+}
+
+// bytes writes a length-delimited field to the pbuf.
+func (b *pbuf) bytes(n int, bs []byte) {
+	b.varint(n<<3 | 2)
+	b.varint(len(bs))
+	b.Write(bs)
+}
+
+// int writes a varint field to the pbuf.
+func (b *pbuf) int(n, i int) {
+	b.varint(n << 3)
+	b.varint(i)
+}
+
+// ints writes a packed repeated varint field to the pbuf.
+func (b *pbuf) ints(n int, is []int) {
+	var m pbuf
+	for _, i := range is {
+		m.varint(i)
+	}
+
+	b.bytes(n, m.Bytes())
+}
+
+// varint writes an integer to the pbuf as a varint.
+func (b *pbuf) varint(i int) {
+	b.Write(binary.AppendUvarint(nil, uint64(i)))
+}
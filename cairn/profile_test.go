@@ -0,0 +1,143 @@
+package cairn
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func xProfiler(ss ...*Stat) *Profiler {
+	c, _ := xCairn("")
+	p := NewProfiler(c)
+	p.Stats = make(map[string]*Stat)
+	for _, s := range ss {
+		p.Stats[s.Name] = s
+	}
+
+	return p
+}
+
+func TestNewProfiler(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+
+	// success
+	p := NewProfiler(c)
+	assert.Equal(t, c, p.Cairn)
+	assert.Equal(t, 1, p.Stats[TopName].Calls)
+	assert.Equal(t, []string{TopName}, p.Samples[TopName].Names)
+	assert.NotZero(t, p.Start)
+	assert.Contains(t, c.Machine.Hooks, p)
+}
+
+func TestProfilerHooks(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	p := NewProfiler(c)
+
+	// success
+	err := c.Execute("def foo dup dup end\n1 foo foo")
+	p.Stop()
+	assert.Equal(t, 2, p.Stats["foo"].Calls)
+	assert.Equal(t, 4, p.Stats["foo"].Instrs)
+	assert.Equal(t, 4, p.Stats["dup"].Calls)
+	assert.Equal(t, 4, p.Stats[TopName].Instrs)
+	assert.Equal(t, []string{TopName, "foo", "dup"}, p.Samples[TopName+"\x00foo\x00dup"].Names)
+	assert.Equal(t, 4, p.Samples[TopName+"\x00foo\x00dup"].Calls)
+	assert.Empty(t, p.calls)
+	assert.NoError(t, err)
+}
+
+func TestProfilerSorted(t *testing.T) {
+	// setup
+	p := xProfiler(
+		&Stat{Name: "b", Exclusive: 1},
+		&Stat{Name: "a", Exclusive: 1},
+		&Stat{Name: "c", Exclusive: 2},
+	)
+
+	// success
+	ss := p.Sorted()
+	assert.Equal(t, "c", ss[0].Name)
+	assert.Equal(t, "a", ss[1].Name)
+	assert.Equal(t, "b", ss[2].Name)
+}
+
+func TestProfilerStop(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	p := NewProfiler(c)
+
+	// success
+	p.Stop()
+	assert.Empty(t, p.calls)
+	assert.NotZero(t, p.Stats[TopName].Inclusive)
+}
+
+func TestProfilerWriteProfile(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	p := NewProfiler(c)
+	c.Execute("1 dup")
+	p.Stop()
+	b := bytes.NewBuffer(nil)
+
+	// success
+	err := p.WriteProfile(b)
+	assert.NoError(t, err)
+
+	z, err := gzip.NewReader(b)
+	assert.NoError(t, err)
+	bs, err := io.ReadAll(z)
+	assert.NoError(t, err)
+	assert.Contains(t, string(bs), "\x32\x00")
+	assert.Contains(t, string(bs), "\x32\x05calls")
+	assert.Contains(t, string(bs), "\x32\x05(top)")
+	assert.Contains(t, string(bs), "\x32\x03dup")
+}
+
+func TestProfilerWriteReport(t *testing.T) {
+	// setup
+	p := xProfiler(&Stat{"foo", 1, 2, 3 * time.Second, time.Second})
+	b := bytes.NewBuffer(nil)
+
+	// success
+	err := p.WriteReport(b)
+	assert.Equal(t, ""+
+		"     calls     instrs    inclusive    exclusive  function\n"+
+		"         1          2           3s           1s  foo\n", b.String())
+	assert.NoError(t, err)
+}
+
+func TestProfilerEnterExit(t *testing.T) {
+	// setup
+	p := xProfiler()
+	p.calls = nil
+	t0 := time.Unix(0, 0)
+
+	// success - nested calls
+	p.enter("a", t0)
+	p.enter("b", t0.Add(1))
+	p.enter("a", t0.Add(2))
+	p.exit(t0.Add(4))
+	p.exit(t0.Add(5))
+	p.exit(t0.Add(10))
+	assert.Equal(t, &Stat{"a", 2, 0, 10, 8}, p.Stats["a"])
+	assert.Equal(t, &Stat{"b", 1, 0, 4, 2}, p.Stats["b"])
+	assert.Equal(t, time.Duration(2), p.Samples["a\x00b\x00a"].Time)
+}
+
+func TestPbuf(t *testing.T) {
+	// setup
+	var b pbuf
+
+	// success
+	b.int(1, 300)
+	b.bytes(2, []byte("ab"))
+	b.ints(3, []int{1, 2})
+	assert.Equal(t, []byte{0x08, 0xac, 0x02, 0x12, 0x02, 'a', 'b', 0x1a, 0x02, 0x01, 0x02}, b.Bytes())
+}
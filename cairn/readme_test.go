@@ -0,0 +1,32 @@
+package cairn
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadme(t *testing.T) {
+	// setup
+	bs, err := os.ReadFile("../readme.md")
+	assert.NoError(t, err)
+
+	s := strings.SplitN(string(bs), "## Examples", 2)[1]
+	s = strings.SplitN(s, "```", 3)[1]
+
+	// success - readme examples
+	for _, l := range strings.Split(strings.TrimSpace(s), "\n") {
+		c, _ := xCairn("")
+		ss := strings.SplitN(l, "//", 2)
+		err := c.Execute(ss[0])
+		want := strings.TrimSpace(ss[1])
+		if want == "_" {
+			want = ""
+		}
+
+		assert.Equal(t, want, c.Stack.String(), l)
+		assert.NoError(t, err, l)
+	}
+}
@@ -0,0 +1,172 @@
+package cairn
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Repl is an interactive prompt that reads code through an Editor and evaluates it
+// against a Cairn, continuing unfinished blocks across lines.
+type Repl struct {
+	Cairn  *Cairn
+	Editor *Editor
+	Errors io.Writer
+	reset  *Dict
+}
+
+// replHelp is the Repl's meta-command summary.
+const replHelp = `Commands:
+  :stack      show the stack
+  :regs       show the registers
+  :words      show all function names
+  :reset      clear the stack and registers and forget new functions
+  :load FILE  run a file
+  :help       show this summary
+`
+
+// NewRepl returns a pointer to a new Repl for a Cairn, reading through an Editor
+// and writing errors to a Writer.
+func NewRepl(c *Cairn, e *Editor, w io.Writer) *Repl {
+	return &Repl{c, e, w, c.Dict.Snapshot()}
+}
+
+// Command runs a meta-command string starting with a colon, returning any ExitError
+// from a loaded file.
+func (r *Repl) Command(s string) error {
+	ss := strings.Fields(strings.TrimPrefix(s, ":"))
+	if len(ss) == 0 {
+		return fmt.Errorf("command is empty")
+	}
+
+	c := r.Cairn
+	switch strings.ToLower(ss[0]) {
+	case "stack":
+		c.WriteString("[ %s ]\n", c.Stack.String())
+
+	case "regs":
+		c.WriteString("[ %s ]\n", c.Table.String())
+
+	case "words":
+		c.WriteString("%s\n", strings.Join(c.Dict.Names(), " "))
+
+	case "reset":
+		c.Stack.Clear()
+		c.Table.Clear()
+		c.Queue.Clear()
+		c.Dict.Restore(r.reset)
+
+	case "load":
+		if len(ss) != 2 {
+			return fmt.Errorf("command %q needs one file", ss[0])
+		}
+
+		bs, err := os.ReadFile(ss[1])
+		if err != nil {
+			return err
+		}
+
+		if _, err := r.execute(ss[1], string(bs)); err != nil {
+			return err
+		}
+
+	case "help":
+		c.WriteString(replHelp)
+
+	default:
+		return fmt.Errorf("unknown command %q", ss[0])
+	}
+
+	return nil
+}
+
+// Read returns the code of one or more lines from the Repl's Editor, reading more
+// lines while it has unclosed blocks and reporting history errors.
+func (r *Repl) Read() (string, error) {
+	s, err := r.Editor.ReadLine(">>> ")
+	if err != nil {
+		return "", err
+	}
+
+	r.add(s)
+	for Unclosed(s) > 0 {
+		l, err := r.Editor.ReadLine("... ")
+		if err != nil {
+			return s, nil
+		}
+
+		r.add(l)
+		s += "\n" + l
+	}
+
+	return s, nil
+}
+
+// Run reads and evaluates code until the end of input, writing the stack after each
+// evaluation and returning any ExitError.
+func (r *Repl) Run() error {
+	c := r.Cairn
+	for {
+		s, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var e *ExitError
+		if strings.HasPrefix(strings.TrimSpace(s), ":") {
+			if err := r.Command(strings.TrimSpace(s)); errors.As(err, &e) {
+				return err
+			} else if err != nil {
+				fmt.Fprint(r.Errors, Report(err, nil))
+			}
+
+			continue
+		}
+
+		if ok, err := r.execute("", s); err != nil {
+			return err
+		} else if ok && !c.Stack.Empty() {
+			c.WriteString("[ %s ]\n", c.Stack.String())
+		}
+	}
+}
+
+// add adds a line to the Repl's Editor history, reporting an error writing the
+// history file and no longer writing to it.
+func (r *Repl) add(s string) {
+	if err := r.Editor.Add(s); err != nil {
+		fmt.Fprint(r.Errors, Report(fmt.Errorf("cannot write history: %w", err), nil))
+		r.Editor.File = ""
+	}
+}
+
+// execute evaluates a named program string against the Repl's Cairn and returns
+// true if it succeeded, clearing the Queue and reporting any error except an
+// ExitError, which it returns.
+func (r *Repl) execute(f, s string) (bool, error) {
+	var e *ExitError
+	if err := r.Cairn.ExecuteFile(f, s); errors.As(err, &e) {
+		return false, err
+	} else if err != nil {
+		r.Cairn.Queue.Clear()
+		fmt.Fprint(r.Errors, Report(err, map[string]string{f: s}))
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// Unclosed returns the number of blocks opened but not closed in a program string.
+func Unclosed(s string) int {
+	var n int
+	w := blockWalker{ts: Tokenise("", s)}
+	for b, ok := w.next(); ok; b, ok = w.next() {
+		n += b.depth
+	}
+
+	return n
+}
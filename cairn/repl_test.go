@@ -0,0 +1,162 @@
+package cairn
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wirehaiku/cairn/vm"
+)
+
+func xRepl(s string) (*Repl, *bytes.Buffer, *bytes.Buffer) {
+	c, b := xCairn("")
+	c.Input = bufio.NewReader(strings.NewReader(s))
+	eb := bytes.NewBuffer(nil)
+	return NewRepl(c, NewEditor(c.Input, c.Output), eb), b, eb
+}
+
+func TestNewRepl(t *testing.T) {
+	// success
+	r, _, eb := xRepl("")
+	assert.NotNil(t, r.Cairn)
+	assert.NotNil(t, r.Editor)
+	assert.Equal(t, eb, r.Errors)
+	assert.NotNil(t, r.reset)
+}
+
+func TestReplCommand(t *testing.T) {
+	// setup
+	r, b, _ := xRepl("")
+	r.Cairn.Execute("1 2 3 0 set def foo 1 end")
+	p := filepath.Join(t.TempDir(), "a.cairn")
+	os.WriteFile(p, []byte("4 5"), 0666)
+
+	// success - stack
+	err := r.Command(":stack")
+	assert.Equal(t, "[ 1 2 ]\n", b.String())
+	assert.NoError(t, err)
+
+	// success - regs
+	b.Reset()
+	err = r.Command(":regs")
+	assert.Equal(t, "[ 0:3 ]\n", b.String())
+	assert.NoError(t, err)
+
+	// success - words
+	b.Reset()
+	err = r.Command(":words")
+	assert.Contains(t, b.String(), " foo ")
+	assert.NoError(t, err)
+
+	// success - reset
+	err = r.Command(":reset")
+	assert.Empty(t, r.Cairn.Stack.Integers)
+	assert.Empty(t, r.Cairn.Table.Integers)
+	assert.False(t, r.Cairn.Dict.Has("foo"))
+	assert.NoError(t, err)
+
+	// success - load
+	err = r.Command(":load " + p)
+	assert.Equal(t, []int{4, 5}, r.Cairn.Stack.Integers)
+	assert.NoError(t, err)
+
+	// success - load with an error
+	os.WriteFile(p, []byte("1 drop drop 5 6"), 0666)
+	err = r.Command(":load " + p)
+	assert.Empty(t, r.Cairn.Queue.Atoms)
+	assert.NoError(t, err)
+
+	// failure - load with an exit
+	os.WriteFile(p, []byte("bye 7"), 0666)
+	err = r.Command(":load " + p)
+	assert.ErrorAs(t, err, new(*ExitError))
+
+	// failure - missing file
+	err = r.Command(":load")
+	assert.EqualError(t, err, `command "load" needs one file`)
+
+	// failure - unknown command
+	err = r.Command(":nope")
+	assert.EqualError(t, err, `unknown command "nope"`)
+}
+
+func TestReplRead(t *testing.T) {
+	// success
+	r, _, _ := xRepl("def foo\n  1\nend 2\n3\n")
+	s, err := r.Read()
+	assert.Equal(t, "def foo\n  1\nend 2", s)
+	assert.Equal(t, []string{"def foo", "  1", "end 2"}, r.Editor.History)
+	assert.NoError(t, err)
+
+	// success - single line
+	s, err = r.Read()
+	assert.Equal(t, "3", s)
+	assert.NoError(t, err)
+
+	// success - history error
+	r, _, eb := xRepl("4\n5\n")
+	r.Editor.File = t.TempDir()
+	r.Read()
+	r.Read()
+	assert.Equal(t, 1, strings.Count(eb.String(), "cannot write history"))
+	assert.Empty(t, r.Editor.File)
+	assert.Equal(t, []string{"4", "5"}, r.Editor.History)
+}
+
+func TestReplRun(t *testing.T) {
+	// success
+	r, b, eb := xRepl("def foo\n1 2\nend\nfoo\n:stack\nnope\n")
+	err := r.Run()
+	assert.Equal(t, ">>> ... ... >>> [ 1 2 ]\n>>> [ 1 2 ]\n>>> >>> \n", b.String())
+	assert.Contains(t, eb.String(), `function "nope" does not exist`)
+	assert.NoError(t, err)
+
+	// success - failed load does not run on
+	p := filepath.Join(t.TempDir(), "a.cairn")
+	os.WriteFile(p, []byte("1 drop drop 5 6"), 0666)
+	r, b, eb = xRepl(":load " + p + "\n7\n")
+	err = r.Run()
+	assert.Equal(t, ">>> >>> [ 7 ]\n>>> \n", b.String())
+	assert.Contains(t, eb.String(), "stack is empty")
+	assert.NoError(t, err)
+
+	// success - limits apply to each line
+	r, b, eb = xRepl("1 drop\n2 drop\n3 drop\n")
+	r.Cairn.Machine.Limits = vm.Limits{Instrs: 2}
+	err = r.Run()
+	assert.Equal(t, ">>> >>> >>> >>> \n", b.String())
+	assert.Empty(t, eb.String())
+	assert.NoError(t, err)
+
+	// failure - exit
+	r, _, _ = xRepl("3 bye\n4\n")
+	err = r.Run()
+	assert.ErrorAs(t, err, new(*ExitError))
+
+	// failure - exit in a loaded file
+	os.WriteFile(p, []byte("bye"), 0666)
+	r, _, _ = xRepl(":load " + p + "\n")
+	err = r.Run()
+	assert.ErrorAs(t, err, new(*ExitError))
+}
+
+func TestUnclosed(t *testing.T) {
+	// success
+	for s, n := range map[string]int{
+		"1 2":                 0,
+		"def foo":             1,
+		"def end":             1,
+		"1 ift for 0":         2,
+		"tst 1 end":           0,
+		"// def":              0,
+		"def foo 1 end end":   -1,
+		"iff def foo 1 end 1": 1,
+		"def foo help end":    1,
+	} {
+		assert.Equal(t, n, Unclosed(s), s)
+	}
+}
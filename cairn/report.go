@@ -0,0 +1,71 @@
+package cairn
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wirehaiku/cairn/vm"
+)
+
+// Report returns an error as a message with a caret-style snippet of the offending
+// line and the chain of function calls, with repeated calls collapsed, using a map of
+// program strings by file name.
+func Report(err error, sm map[string]string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Error: %s.\n", err)
+
+	var e *vm.Error
+	if !errors.As(err, &e) || e.Pos.Line == 0 {
+		return b.String()
+	}
+
+	b.WriteString(snippet(e.Pos, sm))
+	for i := 0; i < len(e.Trace); i++ {
+		s, n := e.Trace[i], 1
+		for i+1 < len(e.Trace) && e.Trace[i+1] == s {
+			i, n = i+1, n+1
+		}
+
+		fmt.Fprintf(&b, "  in %q called at %s", s.Name, s.Pos)
+		if n > 1 {
+			fmt.Fprintf(&b, " (%d times)", n)
+		}
+
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// indent returns the whitespace preceding a column in a line, preserving tabs.
+func indent(l string, col int) string {
+	var rs []rune
+	for i, r := range []rune(l) {
+		if i >= col-1 {
+			break
+		}
+
+		if r != '\t' {
+			r = ' '
+		}
+
+		rs = append(rs, r)
+	}
+
+	return string(rs)
+}
+
+// snippet returns a caret-style snippet of the line at a source position, using a
+// map of program strings by file name.
+func snippet(pos vm.Pos, sm map[string]string) string {
+	ls := strings.Split(sm[pos.File], "\n")
+	if pos.Line == 0 || pos.Line > len(ls) {
+		return ""
+	}
+
+	l := strings.TrimRight(ls[pos.Line-1], "\r")
+	n := strconv.Itoa(pos.Line)
+	return fmt.Sprintf(" %s | %s\n %s | %s^\n", n, l, strings.Repeat(" ", len(n)), indent(l, pos.Col))
+}
@@ -0,0 +1,56 @@
+package cairn
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wirehaiku/cairn/vm"
+)
+
+func TestReport(t *testing.T) {
+	// setup
+	sm := map[string]string{"a.txt": "1 2 +\n\tdef foo bar end\n"}
+	err := &vm.Error{
+		Err:   errors.New("test"),
+		Pos:   vm.Pos{File: "a.txt", Line: 2, Col: 10},
+		Trace: []vm.Site{{Name: "foo", Pos: vm.Pos{File: "a.txt", Line: 1, Col: 5}}},
+	}
+
+	// success - positioned error
+	s := Report(err, sm)
+	assert.Equal(t, "Error: a.txt:2:10: test.\n"+
+		" 2 | \tdef foo bar end\n"+
+		"   | \t        ^\n"+
+		"  in \"foo\" called at a.txt:1:5\n", s)
+
+	// success - repeated calls
+	site := vm.Site{Name: "foo", Pos: vm.Pos{File: "a.txt", Line: 2, Col: 9}}
+	err.Trace = []vm.Site{site, site, site, err.Trace[0]}
+	s = Report(err, sm)
+	assert.Contains(t, s, "  in \"foo\" called at a.txt:2:9 (3 times)\n"+
+		"  in \"foo\" called at a.txt:1:5\n")
+
+	// success - plain error
+	s = Report(errors.New("test"), sm)
+	assert.Equal(t, "Error: test.\n", s)
+}
+
+func TestIndent(t *testing.T) {
+	// success
+	s := indent("\tab cd", 5)
+	assert.Equal(t, "\t   ", s)
+}
+
+func TestSnippet(t *testing.T) {
+	// setup
+	sm := map[string]string{"a.txt": "1 2 +\n"}
+
+	// success
+	s := snippet(vm.Pos{File: "a.txt", Line: 1, Col: 3}, sm)
+	assert.Equal(t, " 1 | 1 2 +\n   |   ^\n", s)
+
+	// success - no line
+	s = snippet(vm.Pos{File: "a.txt", Line: 9, Col: 1}, sm)
+	assert.Empty(t, s)
+}
@@ -31,6 +31,35 @@ func (s *Stack) Len() int {
 	return len(s.Integers)
 }
 
+// Peek returns the top integer on the Stack.
+func (s *Stack) Peek() (int, error) {
+	return s.Pick(0)
+}
+
+// PeekN returns the top N integers on the Stack without removing them.
+func (s *Stack) PeekN(n int) ([]int, error) {
+	var is []int
+	for len(is) < n {
+		i, err := s.Pick(len(is))
+		if err != nil {
+			return nil, err
+		}
+
+		is = append(is, i)
+	}
+
+	return is, nil
+}
+
+// Pick returns the integer N places below the top of the Stack.
+func (s *Stack) Pick(n int) (int, error) {
+	if n < 0 || n >= len(s.Integers) {
+		return 0, fmt.Errorf("stack is empty")
+	}
+
+	return s.Integers[len(s.Integers)-1-n], nil
+}
+
 // Pop removes and returns the top integer on the Stack.
 func (s *Stack) Pop() (int, error) {
 	if len(s.Integers) == 0 {
@@ -85,6 +114,19 @@ func (s *Stack) PushAll(is []int) {
 	s.Integers = append(s.Integers, is...)
 }
 
+// Roll moves the integer N places below the top of the Stack to the top.
+func (s *Stack) Roll(n int) error {
+	i, err := s.Pick(n)
+	if err != nil {
+		return err
+	}
+
+	j := len(s.Integers) - 1 - n
+	copy(s.Integers[j:], s.Integers[j+1:])
+	s.Integers[len(s.Integers)-1] = i
+	return nil
+}
+
 // String returns the Stack as a string.
 func (s *Stack) String() string {
 	var ss []string
@@ -37,6 +37,53 @@ func TestStackLen(t *testing.T) {
 	assert.Equal(t, 3, n)
 }
 
+func TestStackPeek(t *testing.T) {
+	// setup
+	s := NewStack(1, 2, 3)
+
+	// success
+	i, err := s.Peek()
+	assert.Equal(t, 3, i)
+	assert.Equal(t, []int{1, 2, 3}, s.Integers)
+	assert.NoError(t, err)
+
+	// failure - stack is empty
+	i, err = NewStack().Peek()
+	assert.Zero(t, i)
+	assert.EqualError(t, err, "stack is empty")
+}
+
+func TestStackPeekN(t *testing.T) {
+	// setup
+	s := NewStack(1, 2, 3)
+
+	// success
+	is, err := s.PeekN(2)
+	assert.Equal(t, []int{3, 2}, is)
+	assert.Equal(t, []int{1, 2, 3}, s.Integers)
+	assert.NoError(t, err)
+
+	// failure - stack is empty
+	is, err = s.PeekN(4)
+	assert.Nil(t, is)
+	assert.EqualError(t, err, "stack is empty")
+}
+
+func TestStackPick(t *testing.T) {
+	// setup
+	s := NewStack(1, 2, 3)
+
+	// success
+	i, err := s.Pick(2)
+	assert.Equal(t, 1, i)
+	assert.NoError(t, err)
+
+	// failure - stack is empty
+	i, err = s.Pick(3)
+	assert.Zero(t, i)
+	assert.EqualError(t, err, "stack is empty")
+}
+
 func TestStackPop(t *testing.T) {
 	// setup
 	s := NewStack(1)
@@ -98,6 +145,20 @@ func TestStackPushAll(t *testing.T) {
 	assert.Equal(t, []int{1, 2, 3, 4, 5, 6}, s.Integers)
 }
 
+func TestStackRoll(t *testing.T) {
+	// setup
+	s := NewStack(1, 2, 3)
+
+	// success
+	err := s.Roll(2)
+	assert.Equal(t, []int{2, 3, 1}, s.Integers)
+	assert.NoError(t, err)
+
+	// failure - stack is empty
+	err = s.Roll(3)
+	assert.EqualError(t, err, "stack is empty")
+}
+
 func TestStackString(t *testing.T) {
 	// success
 	s := NewStack(1, 2, 3).String()
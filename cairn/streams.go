@@ -0,0 +1,77 @@
+package cairn
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// Streams is a set of input, output and error streams opened from file names.
+type Streams struct {
+	In    io.Reader
+	Out   io.Writer
+	Err   io.Writer
+	files []*os.File
+}
+
+// OpenStreams returns a pointer to a new Streams from input, output and error file
+// names, with "-" meaning the standard streams and output files appended to if app
+// is true.
+func OpenStreams(in, out, err string, app bool) (*Streams, error) {
+	s := &Streams{In: os.Stdin, Out: os.Stdout, Err: os.Stderr}
+
+	if in != "-" {
+		f, err := os.Open(in)
+		if err != nil {
+			return nil, s.fail(err)
+		}
+
+		s.In = f
+		s.files = append(s.files, f)
+	}
+
+	for _, p := range []struct {
+		name string
+		w    *io.Writer
+	}{{out, &s.Out}, {err, &s.Err}} {
+		if p.name == "-" {
+			continue
+		}
+
+		f, err := create(p.name, app)
+		if err != nil {
+			return nil, s.fail(err)
+		}
+
+		*p.w = f
+		s.files = append(s.files, f)
+	}
+
+	return s, nil
+}
+
+// Close closes all files opened by the Streams.
+func (s *Streams) Close() error {
+	var errs []error
+	for _, f := range s.files {
+		errs = append(errs, f.Close())
+	}
+
+	s.files = nil
+	return errors.Join(errs...)
+}
+
+// fail closes all files opened by the Streams and returns an error.
+func (s *Streams) fail(err error) error {
+	s.Close()
+	return err
+}
+
+// create returns a new or truncated file, or a file opened for appending if app is true.
+func create(name string, app bool) (*os.File, error) {
+	if app {
+		return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	}
+
+	return os.Create(name)
+}
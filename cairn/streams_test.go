@@ -0,0 +1,66 @@
+package cairn
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenStreams(t *testing.T) {
+	// setup
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.txt")
+	out := filepath.Join(dir, "out.txt")
+	os.WriteFile(in, []byte("in"), 0666)
+	os.WriteFile(out, []byte("old "), 0666)
+
+	// success - standard streams
+	s, err := OpenStreams("-", "-", "-", false)
+	assert.Equal(t, os.Stdin, s.In)
+	assert.Equal(t, os.Stdout, s.Out)
+	assert.Equal(t, os.Stderr, s.Err)
+	assert.NoError(t, err)
+
+	// success - files
+	s, err = OpenStreams(in, out, "-", false)
+	assert.NoError(t, err)
+
+	bs, _ := io.ReadAll(s.In)
+	io.WriteString(s.Out, "new")
+	s.Close()
+	bs2, _ := os.ReadFile(out)
+	assert.Equal(t, "in", string(bs))
+	assert.Equal(t, "new", string(bs2))
+
+	// success - appended files
+	s, err = OpenStreams("-", "-", out, true)
+	assert.NoError(t, err)
+
+	io.WriteString(s.Err, " err")
+	s.Close()
+	bs, _ = os.ReadFile(out)
+	assert.Equal(t, "new err", string(bs))
+
+	// failure - missing input
+	s, err = OpenStreams(filepath.Join(dir, "nope.txt"), "-", "-", false)
+	assert.Nil(t, s)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// failure - invalid output
+	s, err = OpenStreams(in, filepath.Join(dir, "nope", "out.txt"), "-", false)
+	assert.Nil(t, s)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestStreamsClose(t *testing.T) {
+	// setup
+	s, _ := OpenStreams("-", filepath.Join(t.TempDir(), "out.txt"), "-", false)
+
+	// success
+	err := s.Close()
+	assert.Empty(t, s.files)
+	assert.NoError(t, err)
+}
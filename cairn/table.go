@@ -1,5 +1,11 @@
 package cairn
 
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
 // Table is an addressable map of stored integers.
 type Table struct {
 	Integers map[int]int
@@ -49,3 +55,20 @@ func (t *Table) Len() int {
 func (t *Table) Set(i, v int) {
 	t.Integers[i] = v
 }
+
+// String returns the Table as a string of "register:value" entries in register order.
+func (t *Table) String() string {
+	var rs []int
+	for r := range t.Integers {
+		rs = append(rs, r)
+	}
+
+	slices.Sort(rs)
+
+	var ss []string
+	for _, r := range rs {
+		ss = append(ss, fmt.Sprintf("%d:%d", r, t.Integers[r]))
+	}
+
+	return strings.Join(ss, " ")
+}
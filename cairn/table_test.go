@@ -83,3 +83,12 @@ func TestTableSet(t *testing.T) {
 	ta.Set(0, 456)
 	assert.Equal(t, map[int]int{0: 456}, ta.Integers)
 }
+
+func TestTableString(t *testing.T) {
+	// setup
+	ta := NewTable(map[int]int{2: 34, 0: 12})
+
+	// success
+	s := ta.String()
+	assert.Equal(t, "0:12 2:34", s)
+}
@@ -0,0 +1,55 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package cairn
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// IsTerminal returns true if a file descriptor is a terminal.
+func IsTerminal(fd uintptr) bool {
+	_, err := getTermios(fd)
+	return err == nil
+}
+
+// MakeRaw puts a terminal file descriptor into raw mode, without line buffering,
+// echo or signal keys, and returns a function restoring its previous mode.
+func MakeRaw(fd uintptr) (func() error, error) {
+	t, err := getTermios(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := *t
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO | syscall.ISIG | syscall.IEXTEN
+	raw.Iflag &^= syscall.IXON
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := setTermios(fd, &raw); err != nil {
+		return nil, err
+	}
+
+	return func() error { return setTermios(fd, t) }, nil
+}
+
+// getTermios returns the terminal settings of a file descriptor.
+func getTermios(fd uintptr) (*syscall.Termios, error) {
+	var t syscall.Termios
+	_, _, e := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlGet, uintptr(unsafe.Pointer(&t)))
+	if e != 0 {
+		return nil, e
+	}
+
+	return &t, nil
+}
+
+// setTermios sets the terminal settings of a file descriptor.
+func setTermios(fd uintptr, t *syscall.Termios) error {
+	_, _, e := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlSet, uintptr(unsafe.Pointer(t)))
+	if e != 0 {
+		return e
+	}
+
+	return nil
+}
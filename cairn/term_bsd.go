@@ -0,0 +1,11 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package cairn
+
+import "syscall"
+
+// Terminal ioctl requests for getting and setting terminal settings.
+const (
+	ioctlGet = syscall.TIOCGETA
+	ioctlSet = syscall.TIOCSETA
+)
@@ -0,0 +1,9 @@
+package cairn
+
+import "syscall"
+
+// Terminal ioctl requests for getting and setting terminal settings.
+const (
+	ioctlGet = syscall.TCGETS
+	ioctlSet = syscall.TCSETS
+)
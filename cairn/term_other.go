@@ -0,0 +1,15 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+
+package cairn
+
+import "errors"
+
+// IsTerminal returns false, since terminals are not supported on this platform.
+func IsTerminal(fd uintptr) bool {
+	return false
+}
+
+// MakeRaw returns an error, since terminals are not supported on this platform.
+func MakeRaw(fd uintptr) (func() error, error) {
+	return nil, errors.New("terminal is not supported")
+}
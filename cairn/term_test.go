@@ -0,0 +1,30 @@
+package cairn
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTerminal(t *testing.T) {
+	// setup
+	f, _ := os.Create(filepath.Join(t.TempDir(), "file"))
+	defer f.Close()
+
+	// success
+	ok := IsTerminal(f.Fd())
+	assert.False(t, ok)
+}
+
+func TestMakeRaw(t *testing.T) {
+	// setup
+	f, _ := os.Create(filepath.Join(t.TempDir(), "file"))
+	defer f.Close()
+
+	// failure - not a terminal
+	restore, err := MakeRaw(f.Fd())
+	assert.Nil(t, restore)
+	assert.Error(t, err)
+}
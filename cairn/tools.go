@@ -11,34 +11,6 @@ func Bool(b bool) int {
 	return 0
 }
 
-// DequeueEnd removes returns all atoms in the Queue up to an "end" atom.
-func DequeueEnd(q *Queue) ([]any, error) {
-	var as []any
-	var ac int = 1
-
-loop:
-	for !q.Empty() {
-		a, err := q.Dequeue()
-		if err != nil {
-			return nil, err
-		}
-
-		as = append(as, a)
-
-		if In(a, []any{"def", "iff", "ift", "for"}) {
-			ac++
-		} else if a == "end" {
-			ac--
-		}
-
-		if a == "end" && ac == 0 {
-			break loop
-		}
-	}
-
-	return as[:len(as)-1], nil
-}
-
 // In returns true if an atom is in a slice.
 func In(a any, as []any) bool {
 	for _, a2 := range as {
@@ -51,14 +23,13 @@ func In(a any, as []any) bool {
 }
 
 // Pure applies a pure integer function to a Cairn.
-func Pure(c *Cairn, n int, f func([]int)) error {
+func Pure(c *Cairn, n int, f func([]int) error) error {
 	is, err := c.Stack.PopN(n)
 	if err != nil {
 		return err
 	}
 
-	f(is)
-	return nil
+	return f(is)
 }
 
 // PurePush applies a pure integer function to a Cairn and pushes the result.
@@ -68,7 +39,27 @@ func PurePush(c *Cairn, n int, f func([]int) int) error {
 		return err
 	}
 
-	c.Stack.Push(f(is))
+	return c.Push(f(is))
+}
+
+// PurePushAll applies a pure integer function to a Cairn and pushes all the results.
+func PurePushAll(c *Cairn, n int, f func([]int) ([]int, error)) error {
+	is, err := c.Stack.PopN(n)
+	if err != nil {
+		return err
+	}
+
+	is, err = f(is)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range is {
+		if err := c.Push(i); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -1,6 +1,7 @@
 package cairn
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -16,17 +17,6 @@ func TestBool(t *testing.T) {
 	assert.Equal(t, 0, i)
 }
 
-func TestDequeueEnd(t *testing.T) {
-	// setup
-	q := NewQueue("ift", 123, "end", "end", "nop")
-
-	// success
-	as, err := DequeueEnd(q)
-	assert.Equal(t, []any{"ift", 123, "end"}, as)
-	assert.Equal(t, []any{"nop"}, q.Atoms)
-	assert.NoError(t, err)
-}
-
 func TestIn(t *testing.T) {
 	// setup
 	as := []any{"a", "b", "c", "d"}
@@ -47,7 +37,10 @@ func TestPure(t *testing.T) {
 	var i int
 
 	// success
-	err := Pure(c, 1, func(is []int) { i = is[0] })
+	err := Pure(c, 1, func(is []int) error {
+		i = is[0]
+		return nil
+	})
 	assert.Equal(t, 123, i)
 	assert.NoError(t, err)
 
@@ -71,6 +64,25 @@ func TestPurePush(t *testing.T) {
 	assert.EqualError(t, err, "stack is empty")
 }
 
+func TestPurePushAll(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	c.Stack.PushAll([]int{1, 2})
+
+	// success
+	err := PurePushAll(c, 2, func(is []int) ([]int, error) { return is, nil })
+	assert.Equal(t, []int{2, 1}, c.Stack.Integers)
+	assert.NoError(t, err)
+
+	// failure - function error
+	err = PurePushAll(c, 2, func(is []int) ([]int, error) { return nil, errors.New("test") })
+	assert.EqualError(t, err, "test")
+
+	// failure - stack is empty
+	err = PurePushAll(c, 2, nil)
+	assert.EqualError(t, err, "stack is empty")
+}
+
 func TestToInteger(t *testing.T) {
 	// success
 	a, err := ToInteger(123)
@@ -0,0 +1,89 @@
+package cairn
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/wirehaiku/cairn/vm"
+)
+
+// Event is a single traced instruction with the Stack before and after it ran.
+type Event struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Col    int    `json:"col"`
+	Depth  int    `json:"depth"`
+	Instr  string `json:"instr"`
+	Before []int  `json:"before"`
+	After  []int  `json:"after"`
+}
+
+// Tracer is a vm.Hook that writes an Event for every instruction a Cairn's Machine
+// runs, as text or JSON Lines.
+type Tracer struct {
+	Cairn  *Cairn
+	Output io.Writer
+	JSON   bool
+	events []Event
+}
+
+// NewTracer returns a pointer to a new Tracer for a Cairn, attached to its Machine.
+func NewTracer(c *Cairn, w io.Writer, js bool) *Tracer {
+	t := &Tracer{Cairn: c, Output: w, JSON: js}
+	c.Machine.Hooks = append(c.Machine.Hooks, t)
+	return t
+}
+
+// After completes and writes the Event for an instruction.
+func (t *Tracer) After(f *vm.Frame, in vm.Instr) error {
+	e := t.events[len(t.events)-1]
+	t.events = t.events[:len(t.events)-1]
+	e.After = append([]int{}, t.Cairn.Stack.Integers...)
+
+	if t.JSON {
+		bs, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(t.Output, "%s\n", bs)
+		return err
+	}
+
+	_, err := fmt.Fprintln(t.Output, e)
+	return err
+}
+
+// Before starts the Event for an instruction.
+func (t *Tracer) Before(f *vm.Frame, in vm.Instr) error {
+	pos := f.Pos()
+	t.events = append(t.events, Event{
+		File:   pos.File,
+		Line:   pos.Line,
+		Col:    pos.Col,
+		Depth:  len(t.Cairn.Machine.Frames),
+		Instr:  describe(t.Cairn.Symbols, in),
+		Before: append([]int{}, t.Cairn.Stack.Integers...),
+	})
+
+	return nil
+}
+
+// String returns the Event as a line of text.
+func (e Event) String() string {
+	pos := vm.Pos{File: e.File, Line: e.Line, Col: e.Col}
+	return fmt.Sprintf("%s %d %s ( %s-- %s)", pos, e.Depth, e.Instr, ints(e.Before), ints(e.After))
+}
+
+// ints returns an integer slice as a string with each integer followed by a space.
+func ints(is []int) string {
+	var b strings.Builder
+	for _, i := range is {
+		b.WriteString(strconv.Itoa(i) + " ")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,72 @@
+package cairn
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTracer(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	b := bytes.NewBuffer(nil)
+
+	// success
+	tr := NewTracer(c, b, true)
+	assert.Equal(t, c, tr.Cairn)
+	assert.Equal(t, b, tr.Output)
+	assert.True(t, tr.JSON)
+	assert.Contains(t, c.Machine.Hooks, tr)
+}
+
+func TestTracerAfter(t *testing.T) {
+	// setup
+	c, _ := xCairn("")
+	b := bytes.NewBuffer(nil)
+	tr := NewTracer(c, b, false)
+
+	// success - text
+	err := c.ExecuteFile("a", "def foo dup end\n1 foo")
+	assert.Equal(t, "a:1:1 1 define foo ( -- )\n"+
+		"a:2:1 1 push 1 ( -- 1 )\n"+
+		"a:1:9 2 call dup ( 1 -- 1 1 )\n"+
+		"a:2:3 1 user foo ( 1 -- 1 1 )\n", b.String())
+	assert.NoError(t, err)
+
+	// success - JSON Lines
+	b.Reset()
+	tr.JSON = true
+	err = c.ExecuteFile("a", "2")
+	assert.Equal(t, `{"file":"a","line":1,"col":1,"depth":1,"instr":"push 2",`+
+		`"before":[1,1],"after":[1,1,2]}`+"\n", b.String())
+	assert.NoError(t, err)
+
+	// failure - failing instruction
+	b.Reset()
+	tr.JSON = false
+	c.Stack.Clear()
+	err = c.ExecuteFile("a", "def bar 1 drop drop end\nbar")
+	assert.Equal(t, "a:1:1 1 define bar ( -- )\n"+
+		"a:1:9 2 push 1 ( -- 1 )\n"+
+		"a:1:11 2 call drop ( 1 -- )\n"+
+		"a:1:16 2 call drop ( -- )\n"+
+		"a:2:1 1 user bar ( -- )\n", b.String())
+	assert.Empty(t, tr.events)
+	assert.Error(t, err)
+}
+
+func TestEventString(t *testing.T) {
+	// setup
+	e := Event{"a", 1, 2, 3, "push 4", []int{1}, []int{1, 4}}
+
+	// success
+	s := e.String()
+	assert.Equal(t, "a:1:2 3 push 4 ( 1 -- 1 4 )", s)
+}
+
+func TestInts(t *testing.T) {
+	// success
+	s := ints([]int{1, 2})
+	assert.Equal(t, "1 2 ", s)
+}
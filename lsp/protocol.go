@@ -0,0 +1,123 @@
+package lsp
+
+import "encoding/json"
+
+// CompletionItem is a suggested completion.
+type CompletionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Diagnostic is a problem in a document.
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity"`
+	Source   string `json:"source"`
+	Message  string `json:"message"`
+}
+
+// DocumentSymbol is a named symbol in a document.
+type DocumentSymbol struct {
+	Name           string `json:"name"`
+	Detail         string `json:"detail,omitempty"`
+	Kind           int    `json:"kind"`
+	Range          Range  `json:"range"`
+	SelectionRange Range  `json:"selectionRange"`
+}
+
+// Hover is the contents shown when hovering over a position.
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    Range         `json:"range"`
+}
+
+// Location is a range in a document.
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// MarkupContent is a Markdown or plain text string.
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// Message is a received JSON-RPC request, response or notification.
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *ResponseError  `json:"error,omitempty"`
+}
+
+// Notification is a sent JSON-RPC notification.
+type Notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+// Response is a sent JSON-RPC response, with either a Result or an Error.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *ResponseError  `json:"error,omitempty"`
+}
+
+// Position is a zero-based line and character offset in a document.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a span between two Positions in a document.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// ResponseError is a JSON-RPC error response.
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// TextDocumentItem is an opened document.
+type TextDocumentItem struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+// TextDocumentPosition is a position in a document.
+type TextDocumentPosition struct {
+	TextDocument TextDocumentItem `json:"textDocument"`
+	Position     Position         `json:"position"`
+}
+
+// Completion and symbol kinds for functions.
+const (
+	CompletionFunction = 3
+	SymbolFunction     = 12
+)
+
+// Diagnostic severities for errors and warnings.
+const (
+	SeverityError   = 1
+	SeverityWarning = 2
+)
+
+// Error returns the ResponseError as a string.
+func (e *ResponseError) Error() string {
+	return e.Message
+}
+
+// JSON-RPC error codes for unknown methods and invalid parameters.
+const (
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+)
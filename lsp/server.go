@@ -0,0 +1,427 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/wirehaiku/cairn/cairn"
+	"github.com/wirehaiku/cairn/vm"
+)
+
+// Server is a Language Server Protocol server for Cairn documents, reading requests
+// from an input and writing responses and diagnostics to an output.
+type Server struct {
+	Input    *bufio.Reader
+	Output   io.Writer
+	Docs     map[string]string
+	Shutdown bool
+}
+
+// errExit is returned by a Server's handler to stop the Server.
+var errExit = errors.New("exit")
+
+// NewServer returns a pointer to a new Server over a Reader and a Writer.
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{Input: bufio.NewReader(r), Output: w, Docs: make(map[string]string)}
+}
+
+// Diagnostics returns the parse errors of a document in a map of open documents as
+// errors, or its lint and stack-effect problems as warnings, using the functions
+// defined in the library and every open document.
+func Diagnostics(docs map[string]string, uri string) []Diagnostic {
+	ds := []Diagnostic{}
+	text := docs[uri]
+	if _, err := cairn.ParseString(uri, text, cairn.DefaultModel); err != nil {
+		var e *vm.Error
+		if errors.As(err, &e) {
+			return append(ds, Diagnostic{span(text, e.Pos), SeverityError, "cairn", e.Err.Error()})
+		}
+
+		return append(ds, Diagnostic{Range{}, SeverityError, "cairn", err.Error()})
+	}
+
+	l := cairn.NewLinter()
+	l.LintFile("library", cairn.Library, cairn.DefaultModel)
+	ch := cairn.NewChecker()
+	ch.CheckFile("library", cairn.Library, cairn.DefaultModel)
+
+	for _, u := range keys(docs) {
+		if u != uri {
+			l.LintFile(u, docs[u], cairn.DefaultModel)
+			ch.CheckFile(u, docs[u], cairn.DefaultModel)
+			for _, d := range cairn.Definitions(u, docs[u]) {
+				l.Defined[d.Name] = true
+			}
+		}
+	}
+
+	l.LintFile(uri, text, cairn.DefaultModel)
+	ch.CheckFile(uri, text, cairn.DefaultModel)
+
+	for _, p := range append(l.Problems, ch.Problems...) {
+		if p.Pos.File == uri {
+			ds = append(ds, Diagnostic{span(text, p.Pos), SeverityWarning, "cairn " + p.Kind, p.Message})
+		}
+	}
+
+	return ds
+}
+
+// ReadMessage returns a Message read from a Reader with a Content-Length header.
+func ReadMessage(r *bufio.Reader) (*Message, error) {
+	n := -1
+	for {
+		s, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		s = strings.TrimSpace(s)
+		if s == "" {
+			break
+		}
+
+		if k, v, ok := strings.Cut(s, ":"); ok && strings.EqualFold(k, "Content-Length") {
+			if n, err = strconv.Atoi(strings.TrimSpace(v)); err != nil {
+				return nil, fmt.Errorf("invalid content length %q", v)
+			}
+		}
+	}
+
+	if n < 0 {
+		return nil, fmt.Errorf("missing content length")
+	}
+
+	bs := make([]byte, n)
+	if _, err := io.ReadFull(r, bs); err != nil {
+		return nil, err
+	}
+
+	var m Message
+	if err := json.Unmarshal(bs, &m); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// WriteMessage writes a value to a Writer as JSON with a Content-Length header.
+func WriteMessage(w io.Writer, v any) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(bs), bs)
+	return err
+}
+
+// Run reads and handles messages until an exit notification or the end of input.
+func (s *Server) Run() error {
+	for {
+		m, err := ReadMessage(s.Input)
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		res, err := s.handle(m)
+		if errors.Is(err, errExit) {
+			return nil
+		}
+
+		if m.ID == nil {
+			continue
+		}
+
+		r := Response{JSONRPC: "2.0", ID: m.ID}
+		var re *ResponseError
+		switch {
+		case errors.As(err, &re):
+			r.Error = re
+		case err != nil:
+			r.Error = &ResponseError{CodeInvalidParams, err.Error()}
+		default:
+			if r.Result, err = json.Marshal(res); err != nil {
+				return err
+			}
+		}
+
+		if err := WriteMessage(s.Output, r); err != nil {
+			return err
+		}
+	}
+}
+
+// complete returns completions for all builtin, library and document functions.
+func (s *Server) complete(uri string) []CompletionItem {
+	dm := make(map[string]string)
+	for n, e := range cairn.Effects {
+		dm[n] = e.String()
+	}
+
+	for _, d := range s.definitions(uri) {
+		dm[d.Name] = d.Comment
+	}
+
+	var cs []CompletionItem
+	for _, n := range keys(dm) {
+		cs = append(cs, CompletionItem{strings.ToUpper(n), CompletionFunction, dm[n]})
+	}
+
+	return cs
+}
+
+// define returns the Location of the definition of the function at a position, or
+// nil if there is none.
+func (s *Server) define(p TextDocumentPosition) *Location {
+	t, ok := token(s.Docs[p.TextDocument.URI], p.Position)
+	if !ok {
+		return nil
+	}
+
+	for _, d := range s.definitions(p.TextDocument.URI) {
+		if d.Name == strings.ToLower(t.Text) && d.Pos.File != "library" {
+			return &Location{d.Pos.File, span(s.Docs[d.Pos.File], d.NamePos)}
+		}
+	}
+
+	return nil
+}
+
+// definitions returns the definitions in a document, followed by those in the other
+// documents in name order and the library.
+func (s *Server) definitions(uri string) []cairn.Definition {
+	ds := cairn.Definitions(uri, s.Docs[uri])
+	for _, u := range keys(s.Docs) {
+		if u != uri {
+			ds = append(ds, cairn.Definitions(u, s.Docs[u])...)
+		}
+	}
+
+	return append(ds, cairn.Definitions("library", cairn.Library)...)
+}
+
+// handle handles a Message and returns its result.
+func (s *Server) handle(m *Message) (any, error) {
+	var p struct {
+		TextDocumentPosition
+		ContentChanges []struct {
+			Text string `json:"text"`
+		} `json:"contentChanges"`
+	}
+
+	if m.Params != nil {
+		if err := json.Unmarshal(m.Params, &p); err != nil {
+			return nil, err
+		}
+	}
+
+	uri := p.TextDocument.URI
+	old := s.Docs[uri]
+	switch m.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":       1,
+				"definitionProvider":     true,
+				"hoverProvider":          true,
+				"completionProvider":     map[string]any{},
+				"documentSymbolProvider": true,
+			},
+			"serverInfo": map[string]any{"name": "cairn"},
+		}, nil
+
+	case "shutdown":
+		s.Shutdown = true
+		return nil, nil
+
+	case "exit":
+		return nil, errExit
+
+	case "textDocument/didOpen":
+		s.Docs[uri] = p.TextDocument.Text
+		return nil, s.publish(uri, old)
+
+	case "textDocument/didChange":
+		if n := len(p.ContentChanges); n > 0 {
+			s.Docs[uri] = p.ContentChanges[n-1].Text
+		}
+
+		return nil, s.publish(uri, old)
+
+	case "textDocument/didClose":
+		delete(s.Docs, uri)
+		return nil, s.publish(uri, old)
+
+	case "textDocument/completion":
+		return s.complete(uri), nil
+
+	case "textDocument/definition":
+		return s.define(p.TextDocumentPosition), nil
+
+	case "textDocument/documentSymbol":
+		return s.symbols(uri), nil
+
+	case "textDocument/hover":
+		return s.hover(p.TextDocumentPosition), nil
+
+	case "initialized", "$/cancelRequest", "$/setTrace":
+		return nil, nil
+
+	default:
+		return nil, &ResponseError{CodeMethodNotFound, fmt.Sprintf("method %q does not exist", m.Method)}
+	}
+}
+
+// hover returns the stack effect and description of the function at a position, or
+// nil if there is none.
+func (s *Server) hover(p TextDocumentPosition) *Hover {
+	text := s.Docs[p.TextDocument.URI]
+	t, ok := token(text, p.Position)
+	if !ok {
+		return nil
+	}
+
+	n := strings.ToLower(t.Text)
+	h := &Hover{MarkupContent{"markdown", ""}, span(text, t.Pos)}
+	for _, d := range s.definitions(p.TextDocument.URI) {
+		if d.Name == n {
+			h.Contents.Value = fmt.Sprintf("`%s` %s", strings.ToUpper(n), d.Comment)
+			return h
+		}
+	}
+
+	if e, ok := cairn.Effects[n]; ok {
+		h.Contents.Value = fmt.Sprintf("`%s` %s", strings.ToUpper(n), e)
+		return h
+	}
+
+	return nil
+}
+
+// publish sends the Diagnostics of a changed document, or none if it is closed,
+// followed by those of the other open documents that use the functions it defined
+// before or after the change.
+func (s *Server) publish(uri, old string) error {
+	ns := make(map[string]bool)
+	for _, text := range []string{old, s.Docs[uri]} {
+		for _, d := range cairn.Definitions(uri, text) {
+			ns[d.Name] = true
+		}
+	}
+
+	us := []string{uri}
+	for _, u := range keys(s.Docs) {
+		if u != uri && uses(s.Docs[u], ns) {
+			us = append(us, u)
+		}
+	}
+
+	for _, u := range us {
+		ds := []Diagnostic{}
+		if _, ok := s.Docs[u]; ok {
+			ds = Diagnostics(s.Docs, u)
+		}
+
+		if err := WriteMessage(s.Output, Notification{"2.0", "textDocument/publishDiagnostics",
+			map[string]any{"uri": u, "diagnostics": ds}}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// symbols returns the function definitions in a document as DocumentSymbols.
+func (s *Server) symbols(uri string) []DocumentSymbol {
+	text := s.Docs[uri]
+	ss := []DocumentSymbol{}
+	for _, d := range cairn.Definitions(uri, text) {
+		r, sr := span(text, d.Pos), span(text, d.NamePos)
+		if d.EndPos.Line != 0 {
+			r.End = span(text, d.EndPos).End
+		} else {
+			r.End = sr.End
+		}
+
+		ss = append(ss, DocumentSymbol{strings.ToUpper(d.Name), d.Comment, SymbolFunction, r, sr})
+	}
+
+	return ss
+}
+
+// keys returns the sorted keys of a string map.
+func keys[V any](m map[string]V) []string {
+	var ss []string
+	for s := range m {
+		ss = append(ss, s)
+	}
+
+	slices.Sort(ss)
+	return ss
+}
+
+// position returns a zero-based Position, with its character in UTF-16 code units,
+// from a one-based source position in the lines of a document.
+func position(ls []string, pos vm.Pos) Position {
+	l, c := max(pos.Line-1, 0), max(pos.Col-1, 0)
+	if l < len(ls) {
+		rs := []rune(ls[l])
+		return Position{l, units(string(rs[:min(c, len(rs))])) + max(c-len(rs), 0)}
+	}
+
+	return Position{l, c}
+}
+
+// span returns the Range of the token starting at a source position in a document,
+// or an empty Range at the position if there is none.
+func span(text string, pos vm.Pos) Range {
+	p := position(strings.Split(text, "\n"), pos)
+	for _, t := range cairn.TokeniseComments(pos.File, text) {
+		if t.Pos.Line == pos.Line && t.Pos.Col == pos.Col {
+			return Range{p, Position{p.Line, p.Character + units(t.Text)}}
+		}
+	}
+
+	return Range{p, p}
+}
+
+// token returns the non-comment token at a Position in a document, and false if
+// there is none.
+func token(text string, p Position) (cairn.Token, bool) {
+	ls := strings.Split(text, "\n")
+	for _, t := range cairn.Tokenise("", text) {
+		start := position(ls, t.Pos)
+		end := start.Character + units(t.Text)
+		if start.Line == p.Line && start.Character <= p.Character && p.Character <= end {
+			return t, true
+		}
+	}
+
+	return cairn.Token{}, false
+}
+
+// uses returns true if a document uses any of a set of function names.
+func uses(text string, ns map[string]bool) bool {
+	for _, t := range cairn.Tokenise("", text) {
+		if ns[strings.ToLower(t.Text)] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// units returns the number of UTF-16 code units in a string.
+func units(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
@@ -0,0 +1,289 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wirehaiku/cairn/vm"
+)
+
+// client is an in-process client for a running Server.
+type client struct {
+	t   *testing.T
+	in  *io.PipeWriter
+	out *bufio.Reader
+	id  int
+}
+
+func xClient(t *testing.T) (*client, chan error) {
+	ir, iw := io.Pipe()
+	or, ow := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- NewServer(ir, ow).Run()
+		ow.Close()
+	}()
+
+	return &client{t, iw, bufio.NewReader(or), 0}, done
+}
+
+func (c *client) notify(method string, params any) {
+	WriteMessage(c.in, map[string]any{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (c *client) read() *Message {
+	m, err := ReadMessage(c.out)
+	assert.NoError(c.t, err)
+	return m
+}
+
+func (c *client) request(method string, params any, v any) *Message {
+	c.id++
+	WriteMessage(c.in, map[string]any{"jsonrpc": "2.0", "id": c.id, "method": method, "params": params})
+	m := c.read()
+	if v != nil {
+		assert.NoError(c.t, json.Unmarshal(m.Result, v))
+	}
+
+	return m
+}
+
+func xPosition(uri string, line, char int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": line, "character": char},
+	}
+}
+
+func TestNewServer(t *testing.T) {
+	// success
+	s := NewServer(strings.NewReader(""), io.Discard)
+	assert.NotNil(t, s.Input)
+	assert.Equal(t, io.Discard, s.Output)
+	assert.Empty(t, s.Docs)
+	assert.False(t, s.Shutdown)
+}
+
+func TestDiagnostics(t *testing.T) {
+	// success - parse error
+	ds := Diagnostics(map[string]string{"a": "1 2\ndef foo"}, "a")
+	assert.Equal(t, []Diagnostic{
+		{Range{Position{1, 0}, Position{1, 3}}, SeverityError, "cairn", `missing "end"`},
+	}, ds)
+
+	// success - problems
+	ds = Diagnostics(map[string]string{"a": "nope 1 add"}, "a")
+	assert.Equal(t, []Diagnostic{
+		{Range{Position{0, 0}, Position{0, 4}}, SeverityWarning, "cairn undefined",
+			`function "nope" is not defined`},
+	}, ds)
+
+	ds = Diagnostics(map[string]string{"a": "1 add"}, "a")
+	assert.Equal(t, []Diagnostic{
+		{Range{Position{0, 2}, Position{0, 5}}, SeverityWarning, "cairn underflow",
+			`stack underflow: "add" needs 2 integers but the stack has 1`},
+	}, ds)
+
+	// success - problems in other documents
+	ds = Diagnostics(map[string]string{"a": "1 add", "b": "1 2 add"}, "b")
+	assert.Empty(t, ds)
+
+	// success - functions defined in other documents
+	ds = Diagnostics(map[string]string{"a": "def foo 1 end\ndef", "b": "foo drop"}, "b")
+	assert.Empty(t, ds)
+
+	// success - no problems
+	ds = Diagnostics(map[string]string{"a": "1 2 add"}, "a")
+	assert.Empty(t, ds)
+}
+
+func TestReadMessage(t *testing.T) {
+	// success
+	r := bufio.NewReader(strings.NewReader("Content-Length: 32\r\n\r\n" +
+		`{"jsonrpc":"2.0","method":"foo"}`))
+	m, err := ReadMessage(r)
+	assert.Equal(t, "foo", m.Method)
+	assert.NoError(t, err)
+
+	// failure - missing length
+	r = bufio.NewReader(strings.NewReader("\r\n{}"))
+	_, err = ReadMessage(r)
+	assert.EqualError(t, err, "missing content length")
+
+	// failure - invalid length
+	r = bufio.NewReader(strings.NewReader("Content-Length: x\r\n\r\n{}"))
+	_, err = ReadMessage(r)
+	assert.EqualError(t, err, `invalid content length " x"`)
+}
+
+func TestWriteMessage(t *testing.T) {
+	// setup
+	b := bytes.NewBuffer(nil)
+
+	// success
+	err := WriteMessage(b, map[string]any{"a": 1})
+	assert.Equal(t, "Content-Length: 7\r\n\r\n{\"a\":1}", b.String())
+	assert.NoError(t, err)
+}
+
+func TestServerRun(t *testing.T) {
+	// setup
+	c, done := xClient(t)
+	uri := "file:///a.cairn"
+	text := "def sq // (a -- b) Square a.\n\tdup *\nend\n3 sq nope\n"
+
+	// success - initialize
+	var init struct {
+		Capabilities map[string]any `json:"capabilities"`
+	}
+
+	c.request("initialize", map[string]any{}, &init)
+	assert.Equal(t, true, init.Capabilities["hoverProvider"])
+	c.notify("initialized", map[string]any{})
+
+	// success - diagnostics
+	c.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "languageId": "cairn", "version": 1, "text": text},
+	})
+
+	m := c.read()
+	assert.Equal(t, "textDocument/publishDiagnostics", m.Method)
+	assert.Contains(t, string(m.Params), `function \"nope\" is not defined`)
+
+	// success - definition
+	var loc Location
+	c.request("textDocument/definition", xPosition(uri, 3, 3), &loc)
+	assert.Equal(t, Location{uri, Range{Position{0, 4}, Position{0, 6}}}, loc)
+
+	// success - hover
+	var h Hover
+	c.request("textDocument/hover", xPosition(uri, 3, 2), &h)
+	assert.Equal(t, "`SQ` (a -- b) Square a.", h.Contents.Value)
+
+	c.request("textDocument/hover", xPosition(uri, 1, 6), &h)
+	assert.Equal(t, "`*` (a b -- c)", h.Contents.Value)
+
+	// success - completion
+	var cs []CompletionItem
+	c.request("textDocument/completion", xPosition(uri, 3, 0), &cs)
+	assert.Contains(t, cs, CompletionItem{"ADD", CompletionFunction, "(a b -- c)"})
+	assert.Contains(t, cs, CompletionItem{"RCLR", CompletionFunction, "Clear registers 0 and 1."})
+	assert.Contains(t, cs, CompletionItem{"SQ", CompletionFunction, "(a -- b) Square a."})
+
+	// success - document symbols
+	var ss []DocumentSymbol
+	c.request("textDocument/documentSymbol", map[string]any{"textDocument": map[string]any{"uri": uri}}, &ss)
+	assert.Equal(t, []DocumentSymbol{{"SQ", "(a -- b) Square a.", SymbolFunction,
+		Range{Position{0, 0}, Position{2, 3}}, Range{Position{0, 4}, Position{0, 6}}}}, ss)
+
+	// success - change and close
+	c.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": 2},
+		"contentChanges": []map[string]any{{"text": "1 2 add"}},
+	})
+
+	m = c.read()
+	assert.Contains(t, string(m.Params), `"diagnostics":[]`)
+
+	c.notify("textDocument/didClose", map[string]any{"textDocument": map[string]any{"uri": uri}})
+	m = c.read()
+	assert.Contains(t, string(m.Params), `"diagnostics":[]`)
+
+	// success - missing results
+	m = c.request("textDocument/hover", xPosition(uri, 9, 9), nil)
+	assert.Equal(t, "null", string(m.Result))
+
+	// failure - unknown method
+	m = c.request("nope", map[string]any{}, nil)
+	assert.Equal(t, &ResponseError{CodeMethodNotFound, `method "nope" does not exist`}, m.Error)
+	assert.Nil(t, m.Result)
+
+	// success - shutdown and exit
+	c.request("shutdown", nil, nil)
+	c.notify("exit", nil)
+	assert.NoError(t, <-done)
+}
+
+func TestServerPublish(t *testing.T) {
+	// setup
+	var b bytes.Buffer
+	s := NewServer(strings.NewReader(""), &b)
+	s.Docs = map[string]string{"a": "def foo 1 end", "b": "FOO drop", "c": "1 drop"}
+
+	// success - documents using its functions
+	err := s.publish("a", "")
+	assert.Equal(t, 2, strings.Count(b.String(), "publishDiagnostics"))
+	assert.Contains(t, b.String(), `"uri":"b"`)
+	assert.NotContains(t, b.String(), `"uri":"c"`)
+	assert.NoError(t, err)
+
+	// success - documents using its removed functions
+	b.Reset()
+	s.Docs["a"] = "def bar 1 end"
+	err = s.publish("a", "def foo 1 end")
+	assert.Contains(t, b.String(), `"uri":"b"`)
+	assert.NoError(t, err)
+
+	// success - no dependent documents
+	b.Reset()
+	err = s.publish("c", "")
+	assert.Equal(t, 1, strings.Count(b.String(), "publishDiagnostics"))
+	assert.NoError(t, err)
+}
+
+func TestPosition(t *testing.T) {
+	// setup
+	ls := []string{"1 2", `"😀" foo`}
+
+	// success
+	p := position(ls, vm.Pos{Line: 2, Col: 5})
+	assert.Equal(t, Position{1, 5}, p)
+
+	// success - past the end
+	p = position(ls, vm.Pos{Line: 3, Col: 2})
+	assert.Equal(t, Position{2, 1}, p)
+}
+
+func TestSpan(t *testing.T) {
+	// success
+	r := span("1 foo", vm.Pos{Line: 1, Col: 3})
+	assert.Equal(t, Range{Position{0, 2}, Position{0, 5}}, r)
+
+	// success - UTF-16 characters
+	r = span(`"😀" foo`, vm.Pos{Line: 1, Col: 5})
+	assert.Equal(t, Range{Position{0, 5}, Position{0, 8}}, r)
+
+	r = span(`"😀"`, vm.Pos{Line: 1, Col: 1})
+	assert.Equal(t, Range{Position{0, 0}, Position{0, 4}}, r)
+
+	// success - no token
+	r = span("1 foo", vm.Pos{Line: 2, Col: 1})
+	assert.Equal(t, Range{Position{1, 0}, Position{1, 0}}, r)
+}
+
+func TestToken(t *testing.T) {
+	// success
+	tk, ok := token("1 foo // x", Position{0, 4})
+	assert.Equal(t, "foo", tk.Text)
+	assert.True(t, ok)
+
+	// success - UTF-16 characters
+	tk, ok = token(`"😀" foo`, Position{0, 6})
+	assert.Equal(t, "foo", tk.Text)
+	assert.True(t, ok)
+
+	// failure - no token
+	_, ok = token("1 foo // x", Position{0, 8})
+	assert.False(t, ok)
+}
+
+func TestUnits(t *testing.T) {
+	// success
+	n := units("a😀")
+	assert.Equal(t, 3, n)
+}
@@ -1,57 +1,393 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/wirehaiku/cairn/cairn"
+	"github.com/wirehaiku/cairn/lsp"
+	"github.com/wirehaiku/cairn/vm"
 )
 
-func die(s string, vs ...any) {
-	s = fmt.Sprintf(s, vs...)
-	fmt.Printf("Error: %s.\n", s)
-	os.Exit(1)
+var stderr io.Writer = os.Stderr
+
+var streams *cairn.Streams
+
+func check(f *cairn.Flags) error {
+	ch := cairn.NewChecker()
+	if err := ch.CheckFile("library", cairn.Library, cairn.DefaultModel); err != nil {
+		return err
+	}
+
+	ok := true
+	for _, p := range f.Files {
+		bs, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		if err := ch.CheckFile(p, string(bs), cairn.DefaultModel); err != nil {
+			fmt.Fprint(stderr, cairn.Report(err, map[string]string{p: string(bs)}))
+			ok = false
+		}
+	}
+
+	for _, p := range ch.Problems {
+		fmt.Fprintln(stderr, p)
+	}
+
+	if !ok || len(ch.Problems) != 0 {
+		return &cairn.ExitError{Code: 1}
+	}
+
+	return nil
+}
+
+func doc(f *cairn.Flags) error {
+	if len(f.Files) == 0 {
+		if err := cairn.WriteDocs(os.Stdout, "Builtin Functions", cairn.BuiltinDocs(), f.Format); err != nil {
+			return err
+		}
+
+		ds := cairn.SourceDocs("library", cairn.Library)
+		return cairn.WriteDocs(os.Stdout, "Library Functions", ds, f.Format)
+	}
+
+	for _, p := range f.Files {
+		bs, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		if err := cairn.WriteDocs(os.Stdout, p, cairn.SourceDocs(p, string(bs)), f.Format); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func try(err error) {
+func format(f *cairn.Flags, sm map[string]string) error {
+	if len(f.Files) == 0 {
+		bs, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		sm[""] = string(bs)
+		s, err := cairn.Format("", string(bs), cairn.DefaultModel)
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(s)
+		return nil
+	}
+
+	ok := true
+	for _, p := range f.Files {
+		bs, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		s, err := cairn.Format(p, string(bs), cairn.DefaultModel)
+		if err != nil {
+			fmt.Fprint(stderr, cairn.Report(err, map[string]string{p: string(bs)}))
+			ok = false
+			continue
+		}
+
+		if f.Diff {
+			fmt.Print(cairn.Diff(p, p+" (formatted)", string(bs), s))
+		}
+
+		if f.Write && s != string(bs) {
+			if err := os.WriteFile(p, []byte(s), 0666); err != nil {
+				return err
+			}
+		}
+
+		if !f.Diff && !f.Write {
+			fmt.Print(s)
+		}
+	}
+
+	if !ok {
+		return &cairn.ExitError{Code: 1}
+	}
+
+	return nil
+}
+
+func lint(f *cairn.Flags) error {
+	l := cairn.NewLinter()
+	l.LintFile("library", cairn.Library, cairn.DefaultModel)
+
+	for _, p := range f.Files {
+		bs, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		l.LintFile(p, string(bs), cairn.DefaultModel)
+	}
+
+	if err := cairn.WriteProblems(os.Stdout, l.Problems, f.JSON); err != nil {
+		return err
+	}
+
+	if len(l.Problems) != 0 {
+		return &cairn.ExitError{Code: 1}
+	}
+
+	return nil
+}
+
+func open(f *cairn.Flags) (*cairn.Streams, error) {
+	ss, err := cairn.OpenStreams(f.In, f.Out, f.Err, f.Append)
 	if err != nil {
-		die(err.Error())
+		return nil, err
 	}
+
+	streams = ss
+	stderr = ss.Err
+	return ss, nil
 }
 
-func main() {
-	c := cairn.NewCairn(os.Stdin, os.Stdout)
-	f, err := cairn.ParseFlags(os.Args[1:])
-	try(err)
-	try(c.Execute(cairn.Library))
+func repl(f *cairn.Flags, c *cairn.Cairn) error {
+	c.WriteString("Cairn version 0.0.0 (2024-03-05).\n")
 
-	if f.Command != "" {
-		try(c.Execute(f.Command))
+	e := cairn.NewEditor(c.Input, c.Output)
+	if f.In == "-" && cairn.IsTerminal(os.Stdin.Fd()) {
+		e.Term = os.Stdin
+		if dir, err := os.UserHomeDir(); err == nil {
+			if err := e.Load(filepath.Join(dir, ".cairn_history")); err != nil {
+				return err
+			}
+		}
+	}
 
-	} else if len(f.Files) != 0 {
+	return cairn.NewRepl(c, e, stderr).Run()
+}
 
-		for _, p := range f.Files {
-			bs, err := os.ReadFile(p)
+func run(f *cairn.Flags, sm map[string]string) error {
+	ss, err := open(f)
+	if err != nil {
+		return err
+	}
+
+	c, err := setup(f, ss)
+	if err != nil {
+		return err
+	}
+
+	if f.Trace != "" {
+		if f.Format != "text" && f.Format != "json" {
+			return fmt.Errorf("trace format %q is not text or json", f.Format)
+		}
+
+		w := stderr
+		if f.Trace != "-" {
+			tf, err := os.Create(f.Trace)
 			if err != nil {
-				die("cannot execute file %q", p)
+				return err
 			}
 
-			try(c.Execute(string(bs)))
+			defer tf.Close()
+			w = tf
 		}
 
-	} else {
-		c.WriteString("Cairn version 0.0.0 (2024-03-05).\n")
+		cairn.NewTracer(c, w, f.Format == "json")
+	}
 
-		for {
-			c.WriteString(">>> ")
-			s := c.ReadString('\n')
+	var pf *os.File
+	var pr *cairn.Profiler
+	if f.Profile != "" {
+		pf, err = os.Create(f.Profile)
+		if err != nil {
+			return err
+		}
 
-			if err := c.Execute(s); err != nil {
-				c.WriteString("Error: %s.\n\n", err.Error())
+		defer pf.Close()
+		pr = cairn.NewProfiler(c)
+	}
 
-			} else if !c.Stack.Empty() {
-				c.WriteString("[ %s ]\n", c.Stack.String())
+	exec := c.ExecuteFile
+	if f.Debug {
+		d := cairn.NewDebugger(c, c.Input, c.Output)
+		d.Sources = sm
+		exec = d.ExecuteFile
+	}
+
+	switch {
+	case f.Command != "":
+		sm[""] = f.Command
+		err = exec("", f.Command)
+
+	case len(f.Files) != 0:
+		for _, p := range f.Files {
+			var bs []byte
+			if bs, err = os.ReadFile(p); err != nil {
+				break
+			}
+
+			sm[p] = string(bs)
+			if err = exec(p, string(bs)); err != nil {
+				break
 			}
 		}
+
+	default:
+		err = repl(f, c)
+	}
+
+	if pr != nil {
+		pr.Stop()
+		err = errors.Join(err, pr.WriteReport(stderr), pr.WriteProfile(pf))
+	}
+
+	return err
+}
+
+func setup(f *cairn.Flags, ss *cairn.Streams) (*cairn.Cairn, error) {
+	c := cairn.NewCairn(ss.In, ss.Out)
+	if err := c.ExecuteFile("library", cairn.Library); err != nil {
+		return nil, err
 	}
+
+	c.Caps = f.Caps
+	c.EOF = f.EOF
+	c.Model.StackCap = f.MaxStack
+	c.Model.TableCap = f.MaxTable
+	if err := c.Model.Validate(); err != nil {
+		return nil, err
+	}
+
+	c.Machine.Limits = vm.Limits{Instrs: f.MaxInstr, Depth: f.MaxDepth}
+	c.Timeout = f.Timeout
+	return c, nil
+}
+
+func test(f *cairn.Flags) error {
+	ss, err := open(f)
+	if err != nil {
+		return err
+	}
+
+	ps := f.Files
+	if len(ps) == 0 {
+		ps, _ = filepath.Glob("*_test.cairn")
+	}
+
+	ok := true
+	for _, p := range ps {
+		bs, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		sm := map[string]string{"library": cairn.Library, p: string(bs)}
+		c, err := setup(f, ss)
+		if err != nil {
+			return err
+		}
+
+		c.Caps &^= cairn.CapExit
+		err = c.ExecuteFile(p, string(bs))
+
+		if err != nil {
+			fmt.Fprintf(ss.Out, "FAIL %s\n", p)
+			fmt.Fprint(stderr, cairn.Report(err, sm))
+			ok = false
+		} else {
+			fmt.Fprintf(ss.Out, "ok   %s\n", p)
+		}
+	}
+
+	if !ok {
+		return &cairn.ExitError{Code: 1}
+	}
+
+	return nil
+}
+
+func command(f *cairn.Flags, sm map[string]string) error {
+	switch f.Name {
+	case "run":
+		return run(f, sm)
+	case "repl":
+		ss, err := open(f)
+		if err != nil {
+			return err
+		}
+
+		c, err := setup(f, ss)
+		if err != nil {
+			return err
+		}
+
+		return repl(f, c)
+	case "test":
+		return test(f)
+	case "fmt":
+		return format(f, sm)
+	case "check":
+		return check(f)
+	case "lint":
+		return lint(f)
+	case "doc":
+		return doc(f)
+	case "lsp":
+		s := lsp.NewServer(os.Stdin, os.Stdout)
+		if err := s.Run(); err != nil {
+			return err
+		}
+
+		if !s.Shutdown {
+			return &cairn.ExitError{Code: 1}
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("command %q is not implemented", f.Name)
+	}
+}
+
+func exit(err error, sm map[string]string) int {
+	var e *cairn.ExitError
+	switch {
+	case errors.As(err, &e):
+		return e.Code
+	case err != nil:
+		fmt.Fprint(stderr, cairn.Report(err, sm))
+		return 1
+	default:
+		return 0
+	}
+}
+
+func main() {
+	f, err := cairn.ParseFlags(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+
+	sm := map[string]string{"library": cairn.Library}
+	if err == nil {
+		err = command(f, sm)
+	}
+
+	code := exit(err, sm)
+	if streams != nil {
+		if err := streams.Close(); err != nil && code == 0 {
+			code = exit(err, sm)
+		}
+	}
+
+	os.Exit(code)
 }
@@ -0,0 +1,73 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Limit errors, wrapped by a LimitError when a resource limit is exceeded.
+var (
+	ErrDepth  = errors.New("call depth limit")
+	ErrInstrs = errors.New("instruction limit")
+	ErrStack  = errors.New("stack limit")
+	ErrTable  = errors.New("table limit")
+)
+
+// Error is an error raised at a source position, with its chain of function calls.
+type Error struct {
+	Err   error
+	Pos   Pos
+	Trace []Site
+}
+
+// LimitError is an error raised when a resource limit is exceeded.
+type LimitError struct {
+	Err error
+	Max int
+}
+
+// Pos is a source position in a program file.
+type Pos struct {
+	File string
+	Line int
+	Col  int
+}
+
+// Site is a user-defined function call site in an Error's trace.
+type Site struct {
+	Name string
+	Pos  Pos
+}
+
+// Error returns the Error as a string.
+func (e *Error) Error() string {
+	if e.Pos.Line == 0 {
+		return e.Err.Error()
+	}
+
+	return fmt.Sprintf("%s: %s", e.Pos, e.Err)
+}
+
+// Unwrap returns the Error's underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Error returns the LimitError as a string.
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s of %d exceeded", e.Err, e.Max)
+}
+
+// Unwrap returns the LimitError's underlying limit error.
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// String returns the Pos as a string.
+func (p Pos) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Col)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Col)
+}
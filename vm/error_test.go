@@ -0,0 +1,50 @@
+package vm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorError(t *testing.T) {
+	// success - with position
+	e := &Error{errors.New("test"), Pos{"a.txt", 1, 2}, nil}
+	assert.Equal(t, "a.txt:1:2: test", e.Error())
+
+	// success - without position
+	e = &Error{errors.New("test"), Pos{}, nil}
+	assert.Equal(t, "test", e.Error())
+}
+
+func TestErrorUnwrap(t *testing.T) {
+	// setup
+	err := errors.New("test")
+
+	// success
+	e := &Error{err, Pos{}, nil}
+	assert.Equal(t, err, e.Unwrap())
+}
+
+func TestLimitErrorError(t *testing.T) {
+	// success
+	e := &LimitError{ErrStack, 123}
+	assert.Equal(t, "stack limit of 123 exceeded", e.Error())
+}
+
+func TestLimitErrorUnwrap(t *testing.T) {
+	// success
+	e := &LimitError{ErrStack, 123}
+	assert.Equal(t, ErrStack, e.Unwrap())
+	assert.ErrorIs(t, &Error{Err: e}, ErrStack)
+}
+
+func TestPosString(t *testing.T) {
+	// success - with file
+	s := Pos{"a.txt", 1, 2}.String()
+	assert.Equal(t, "a.txt:1:2", s)
+
+	// success - without file
+	s = Pos{"", 1, 2}.String()
+	assert.Equal(t, "1:2", s)
+}
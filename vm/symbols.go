@@ -0,0 +1,33 @@
+package vm
+
+// Symbols is an interned table of symbol names.
+type Symbols struct {
+	IDs   map[string]int
+	Names []string
+}
+
+// NewSymbols returns a pointer to a new Symbols.
+func NewSymbols() *Symbols {
+	return &Symbols{make(map[string]int), nil}
+}
+
+// ID returns the ID of a symbol name, interning it if necessary.
+func (s *Symbols) ID(n string) int {
+	if i, ok := s.IDs[n]; ok {
+		return i
+	}
+
+	s.IDs[n] = len(s.Names)
+	s.Names = append(s.Names, n)
+	return len(s.Names) - 1
+}
+
+// Len returns the number of symbols in the Symbols.
+func (s *Symbols) Len() int {
+	return len(s.Names)
+}
+
+// Name returns the name of a symbol ID.
+func (s *Symbols) Name(i int) string {
+	return s.Names[i]
+}
@@ -0,0 +1,49 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSymbols(t *testing.T) {
+	// success
+	s := NewSymbols()
+	assert.Empty(t, s.IDs)
+	assert.Empty(t, s.Names)
+}
+
+func TestSymbolsID(t *testing.T) {
+	// setup
+	s := NewSymbols()
+
+	// success - new symbol
+	i := s.ID("foo")
+	assert.Equal(t, 0, i)
+	assert.Equal(t, []string{"foo"}, s.Names)
+
+	// success - existing symbol
+	i = s.ID("foo")
+	assert.Equal(t, 0, i)
+	assert.Equal(t, []string{"foo"}, s.Names)
+}
+
+func TestSymbolsLen(t *testing.T) {
+	// setup
+	s := NewSymbols()
+	s.ID("foo")
+
+	// success
+	n := s.Len()
+	assert.Equal(t, 1, n)
+}
+
+func TestSymbolsName(t *testing.T) {
+	// setup
+	s := NewSymbols()
+	s.ID("foo")
+
+	// success
+	n := s.Name(0)
+	assert.Equal(t, "foo", n)
+}
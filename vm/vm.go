@@ -0,0 +1,350 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Op is a virtual machine operation code.
+type Op uint8
+
+const (
+	// Push pushes the integer Arg.
+	Push Op = iota
+	// Call calls the builtin function Arg, or the user-defined function replacing it.
+	Call
+	// User calls the user-defined function Arg.
+	User
+	// Jump jumps to instruction Arg.
+	Jump
+	// BranchFalse pops an integer and jumps to instruction Arg if it is zero.
+	BranchFalse
+	// BranchTrue pops an integer and jumps to instruction Arg if it is non-zero.
+	BranchTrue
+	// Loop jumps to instruction Arg if register Aux is non-zero.
+	Loop
+	// Define sets the user-defined function Arg to sub-program Aux.
+	Define
+	// Assert pops an integer and fails with text Arg if it is zero.
+	Assert
+	// Help writes the documentation of function Arg.
+	Help
+)
+
+// names is the lower-case name of each operation code.
+var names = []string{"push", "call", "user", "jump", "branchfalse", "branchtrue", "loop", "define", "assert", "help"}
+
+// Env is an environment a Machine runs Programs against, calling builtin functions
+// directly and returning user-defined functions for the Machine to push as Frames.
+type Env interface {
+	Push(i int) error
+	Pop() (int, error)
+	Get(r int) (int, error)
+	User(id int) (*Program, error)
+	Define(id int, p *Program)
+	Help(id int) error
+}
+
+// Frame is a Program in progress on a Machine.
+type Frame struct {
+	Program *Program
+	PC      int
+}
+
+// Hook is an observer called before and after a Machine executes each instruction
+// in a Frame, with calls to user-defined functions completing when they return or
+// fail.
+type Hook interface {
+	Before(f *Frame, in Instr) error
+	After(f *Frame, in Instr) error
+}
+
+// Instr is a single virtual machine instruction.
+type Instr struct {
+	Op  Op
+	Arg int
+	Aux int
+}
+
+// DefaultDepth is the default maximum call depth of a new Machine.
+const DefaultDepth = 10000
+
+// Limits is a set of resource limits for a Machine, with zero meaning unlimited.
+type Limits struct {
+	Instrs int
+	Depth  int
+}
+
+// Machine is a virtual machine that runs Programs against an Env, counting the
+// instructions it executes and stopping when its Context is done.
+type Machine struct {
+	Env     Env
+	Frames  []Frame
+	Hooks   []Hook
+	Limits  Limits
+	Context context.Context
+	Count   int
+}
+
+// Program is a compiled sequence of instructions with their source positions, and
+// the name and documentation of the function it defines.
+type Program struct {
+	Name  string
+	Doc   string
+	Code  []Instr
+	Pos   []Pos
+	Subs  []*Program
+	Texts []string
+}
+
+// NewMachine returns a pointer to a new Machine with the default call depth.
+func NewMachine(e Env) *Machine {
+	return &Machine{Env: e, Limits: Limits{Depth: DefaultDepth}}
+}
+
+// Run runs a Program on the Machine until it returns.
+func (m *Machine) Run(p *Program) error {
+	if m.Context != nil {
+		if err := m.Context.Err(); err != nil {
+			return m.wrap(err)
+		}
+	}
+
+	base := len(m.Frames)
+	if err := m.push(p); err != nil {
+		return m.wrap(err)
+	}
+
+	defer func() { m.Frames = m.Frames[:base] }()
+
+	for len(m.Frames) > base {
+		f := &m.Frames[len(m.Frames)-1]
+		if f.PC >= len(f.Program.Code) {
+			m.Frames = m.Frames[:len(m.Frames)-1]
+			if len(m.Hooks) != 0 && len(m.Frames) > base {
+				f := &m.Frames[len(m.Frames)-1]
+				if err := m.after(f, f.Program.Code[f.PC-1]); err != nil {
+					return m.wrap(err)
+				}
+			}
+
+			continue
+		}
+
+		in := f.Program.Code[f.PC]
+		f.PC++
+
+		if err := m.count(); err != nil {
+			return m.fail(base, err)
+		}
+
+		if len(m.Hooks) != 0 {
+			if err := m.hook(len(m.Frames)-1, in); err != nil {
+				return m.fail(base, err)
+			}
+
+			continue
+		}
+
+		if err := m.step(f, in); err != nil {
+			return m.wrap(err)
+		}
+	}
+
+	return nil
+}
+
+// after calls the Machine's Hooks after an instruction in a Frame.
+func (m *Machine) after(f *Frame, in Instr) error {
+	for _, h := range m.Hooks {
+		if err := h.After(f, in); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// count counts an instruction against the Machine's Limits and Context.
+func (m *Machine) count() error {
+	m.Count++
+	if m.Limits.Instrs > 0 && m.Count > m.Limits.Instrs {
+		return &LimitError{ErrInstrs, m.Limits.Instrs}
+	}
+
+	if m.Context != nil && m.Count%1024 == 0 {
+		return m.Context.Err()
+	}
+
+	return nil
+}
+
+// fail returns an error as an Error after calling the Machine's Hooks after the
+// calls still pending in its Frames above a base, innermost first.
+func (m *Machine) fail(base int, err error) error {
+	err = m.wrap(err)
+	for i := len(m.Frames) - 2; i >= base && len(m.Hooks) != 0; i-- {
+		f := &m.Frames[i]
+		m.after(f, f.Program.Code[f.PC-1])
+	}
+
+	return err
+}
+
+// hook executes a single instruction in a Frame by index, calling the Machine's
+// Hooks before it and after it, or after the returning Frame if it calls one.
+func (m *Machine) hook(i int, in Instr) error {
+	for _, h := range m.Hooks {
+		if err := h.Before(&m.Frames[i], in); err != nil {
+			return err
+		}
+	}
+
+	if err := m.step(&m.Frames[i], in); err != nil {
+		m.after(&m.Frames[i], in)
+		return err
+	}
+
+	if len(m.Frames) > i+1 {
+		return nil
+	}
+
+	return m.after(&m.Frames[i], in)
+}
+
+// push appends a new Frame for a Program to the Machine, checking its call depth.
+func (m *Machine) push(p *Program) error {
+	if m.Limits.Depth > 0 && len(m.Frames) >= m.Limits.Depth {
+		return &LimitError{ErrDepth, m.Limits.Depth}
+	}
+
+	m.Frames = append(m.Frames, Frame{p, 0})
+	return nil
+}
+
+// step executes a single instruction in a Frame.
+func (m *Machine) step(f *Frame, in Instr) error {
+	switch in.Op {
+	case Push:
+		return m.Env.Push(in.Arg)
+
+	case Call, User:
+		p, err := m.Env.User(in.Arg)
+		if err != nil {
+			return err
+		}
+
+		if p != nil {
+			return m.push(p)
+		}
+
+		return nil
+
+	case Jump:
+		f.PC = in.Arg
+		return nil
+
+	case BranchFalse, BranchTrue:
+		i, err := m.Env.Pop()
+		if err != nil {
+			return err
+		}
+
+		if (i != 0) == (in.Op == BranchTrue) {
+			f.PC = in.Arg
+		}
+
+		return nil
+
+	case Loop:
+		i, err := m.Env.Get(in.Aux)
+		if err != nil {
+			return err
+		}
+
+		if i != 0 {
+			f.PC = in.Arg
+		}
+
+		return nil
+
+	case Define:
+		m.Env.Define(in.Arg, f.Program.Subs[in.Aux])
+		return nil
+
+	case Assert:
+		i, err := m.Env.Pop()
+		if err != nil {
+			return err
+		}
+
+		if i == 0 {
+			return fmt.Errorf("test %q failed", f.Program.Texts[in.Arg])
+		}
+
+		return nil
+
+	case Help:
+		return m.Env.Help(in.Arg)
+
+	default:
+		return fmt.Errorf("cannot run operation %d", in.Op)
+	}
+}
+
+// Trace returns the chain of user-defined function calls on the Machine, innermost first.
+func (m *Machine) Trace() []Site {
+	var cs []Site
+	for i := len(m.Frames) - 1; i > 0; i-- {
+		if n := m.Frames[i].Program.Name; n != "" {
+			cs = append(cs, Site{n, m.Frames[i-1].Pos()})
+		}
+	}
+
+	return cs
+}
+
+// wrap returns an error as an Error at the Machine's current position.
+func (m *Machine) wrap(err error) error {
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+
+	if len(m.Frames) == 0 {
+		return &Error{Err: err}
+	}
+
+	return &Error{err, m.Frames[len(m.Frames)-1].Pos(), m.Trace()}
+}
+
+// String returns the Op's name.
+func (o Op) String() string {
+	if int(o) < len(names) {
+		return names[o]
+	}
+
+	return fmt.Sprintf("op%d", o)
+}
+
+// Pos returns the source position of the Frame's current instruction.
+func (f Frame) Pos() Pos {
+	if f.PC == 0 || f.PC > len(f.Program.Pos) {
+		return Pos{}
+	}
+
+	return f.Program.Pos[f.PC-1]
+}
+
+// Emit appends an instruction at a source position to the Program and returns its index.
+func (p *Program) Emit(pos Pos, op Op, arg, aux int) int {
+	p.Code = append(p.Code, Instr{op, arg, aux})
+	p.Pos = append(p.Pos, pos)
+	return len(p.Code) - 1
+}
+
+// Patch sets the argument of an instruction in the Program.
+func (p *Program) Patch(i, arg int) {
+	p.Code[i].Arg = arg
+}
@@ -0,0 +1,361 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type xEnv struct {
+	Integers  []int
+	Registers map[int]int
+	Words     map[int]*Program
+}
+
+func (e *xEnv) Push(i int) error {
+	e.Integers = append(e.Integers, i)
+	return nil
+}
+
+func (e *xEnv) Pop() (int, error) {
+	if len(e.Integers) == 0 {
+		return 0, fmt.Errorf("stack is empty")
+	}
+
+	i := e.Integers[len(e.Integers)-1]
+	e.Integers = e.Integers[:len(e.Integers)-1]
+	return i, nil
+}
+
+func (e *xEnv) Get(r int) (int, error) {
+	if r < 0 {
+		return 0, fmt.Errorf("register %d does not exist", r)
+	}
+
+	return e.Registers[r], nil
+}
+
+func (e *xEnv) User(id int) (*Program, error) {
+	if p, ok := e.Words[id]; ok {
+		return p, nil
+	}
+
+	if id == 0 {
+		a, _ := e.Pop()
+		b, _ := e.Pop()
+		return nil, e.Push(a + b)
+	}
+
+	return nil, fmt.Errorf("function %d does not exist", id)
+}
+
+func (e *xEnv) Define(id int, p *Program) {
+	e.Words[id] = p
+}
+
+func (e *xEnv) Help(id int) error {
+	if _, ok := e.Words[id]; !ok {
+		return fmt.Errorf("function %d does not exist", id)
+	}
+
+	return e.Push(id)
+}
+
+type xHook struct {
+	Calls []string
+	Stop  int
+}
+
+func (h *xHook) Before(f *Frame, in Instr) error {
+	if in.Arg == h.Stop {
+		return fmt.Errorf("stop")
+	}
+
+	h.Calls = append(h.Calls, "before "+f.Pos().String())
+	return nil
+}
+
+func (h *xHook) After(f *Frame, in Instr) error {
+	h.Calls = append(h.Calls, "after "+f.Pos().String())
+	return nil
+}
+
+func xMachine() (*Machine, *xEnv) {
+	e := &xEnv{nil, make(map[int]int), make(map[int]*Program)}
+	return NewMachine(e), e
+}
+
+func TestNewMachine(t *testing.T) {
+	// success
+	m, e := xMachine()
+	assert.Equal(t, e, m.Env)
+	assert.Empty(t, m.Frames)
+	assert.Equal(t, DefaultDepth, m.Limits.Depth)
+}
+
+func TestMachineRun(t *testing.T) {
+	// setup
+	m, e := xMachine()
+	p := &Program{Code: []Instr{
+		{Push, 1, 0}, {Push, 2, 0}, {Call, 0, 0},
+	}}
+
+	// success - push and call
+	err := m.Run(p)
+	assert.Equal(t, []int{3}, e.Integers)
+	assert.Empty(t, m.Frames)
+	assert.NoError(t, err)
+
+	// success - branch false
+	e.Integers = []int{0}
+	err = m.Run(&Program{Code: []Instr{
+		{BranchFalse, 2, 0}, {Push, 1, 0}, {Push, 2, 0},
+	}})
+	assert.Equal(t, []int{2}, e.Integers)
+	assert.NoError(t, err)
+
+	// success - branch true
+	e.Integers = []int{1}
+	err = m.Run(&Program{Code: []Instr{
+		{BranchTrue, 2, 0}, {Push, 1, 0}, {Push, 2, 0},
+	}})
+	assert.Equal(t, []int{2}, e.Integers)
+	assert.NoError(t, err)
+
+	// success - jump
+	e.Integers = nil
+	err = m.Run(&Program{Code: []Instr{
+		{Jump, 2, 0}, {Push, 1, 0}, {Push, 2, 0},
+	}})
+	assert.Equal(t, []int{2}, e.Integers)
+	assert.NoError(t, err)
+
+	// success - loop
+	e.Integers = nil
+	err = m.Run(&Program{Code: []Instr{
+		{Push, 1, 0}, {Loop, 0, 0},
+	}})
+	assert.Equal(t, []int{1}, e.Integers)
+	assert.NoError(t, err)
+
+	// success - define and user call
+	e.Integers = nil
+	err = m.Run(&Program{
+		Code: []Instr{{Define, 3, 0}, {User, 3, 0}, {Push, 2, 0}},
+		Subs: []*Program{p},
+	})
+	assert.Equal(t, []int{3, 2}, e.Integers)
+	assert.Empty(t, m.Frames)
+	assert.NoError(t, err)
+
+	// success - assert
+	e.Integers = []int{1}
+	err = m.Run(&Program{Code: []Instr{{Assert, 0, 0}}, Texts: []string{"1"}})
+	assert.Empty(t, e.Integers)
+	assert.NoError(t, err)
+
+	// failure - assert
+	e.Integers = []int{0}
+	err = m.Run(&Program{Code: []Instr{{Assert, 0, 0}}, Texts: []string{"0"}})
+	assert.EqualError(t, err, `test "0" failed`)
+
+	// success - help
+	e.Integers = nil
+	err = m.Run(&Program{Code: []Instr{{Help, 3, 0}}})
+	assert.Equal(t, []int{3}, e.Integers)
+	assert.NoError(t, err)
+
+	// failure - help
+	err = m.Run(&Program{Code: []Instr{{Help, 7, 0}}})
+	assert.EqualError(t, err, "function 7 does not exist")
+
+	// failure - loop register does not exist
+	err = m.Run(&Program{Code: []Instr{{Loop, 0, -1}}})
+	assert.EqualError(t, err, "register -1 does not exist")
+
+	// failure - stack is empty
+	e.Integers = nil
+	err = m.Run(&Program{Code: []Instr{{BranchTrue, 0, 0}}})
+	assert.Empty(t, m.Frames)
+	assert.EqualError(t, err, "stack is empty")
+
+	// failure - positioned error with trace
+	e.Integers = nil
+	e.Words[1] = &Program{
+		Name: "foo",
+		Code: []Instr{{BranchTrue, 0, 0}},
+		Pos:  []Pos{{"", 2, 3}},
+	}
+	err = m.Run(&Program{
+		Code: []Instr{{User, 1, 0}},
+		Pos:  []Pos{{"", 1, 1}},
+	})
+	assert.Equal(t, &Error{
+		fmt.Errorf("stack is empty"),
+		Pos{"", 2, 3},
+		[]Site{{"foo", Pos{"", 1, 1}}},
+	}, err)
+
+	// success - redefined builtin call
+	e.Integers = []int{1, 2}
+	e.Words[4] = &Program{Code: []Instr{{Push, 5, 0}}}
+	err = m.Run(&Program{Code: []Instr{{Call, 4, 0}}})
+	assert.Equal(t, []int{1, 2, 5}, e.Integers)
+	assert.Empty(t, m.Frames)
+	assert.NoError(t, err)
+
+	// failure - invalid operation
+	err = m.Run(&Program{Code: []Instr{{255, 0, 0}}})
+	assert.EqualError(t, err, "cannot run operation 255")
+}
+
+func TestMachineRunHooks(t *testing.T) {
+	// setup
+	m, e := xMachine()
+	h := &xHook{}
+	m.Hooks = []Hook{h}
+	p := &Program{Code: []Instr{{Push, 1, 0}, {Push, 2, 0}}, Pos: []Pos{{"", 1, 1}, {"", 1, 3}}}
+
+	// success
+	err := m.Run(p)
+	assert.Equal(t, []int{1, 2}, e.Integers)
+	assert.Equal(t, []string{"before 1:1", "after 1:1", "before 1:3", "after 1:3"}, h.Calls)
+	assert.NoError(t, err)
+
+	// success - user function completes on return
+	e.Integers = nil
+	h.Calls = nil
+	e.Words[9] = &Program{Code: []Instr{{Push, 3, 0}}, Pos: []Pos{{"", 2, 1}}}
+	err = m.Run(&Program{Code: []Instr{{User, 9, 0}}, Pos: []Pos{{"", 1, 1}}})
+	assert.Equal(t, []int{3}, e.Integers)
+	assert.Equal(t, []string{"before 1:1", "before 2:1", "after 2:1", "after 1:1"}, h.Calls)
+	assert.NoError(t, err)
+
+	// failure - hook error
+	e.Integers = nil
+	h.Calls = nil
+	h.Stop = 2
+
+	err = m.Run(p)
+	assert.Equal(t, []int{1}, e.Integers)
+	assert.Equal(t, []string{"before 1:1", "after 1:1"}, h.Calls)
+	assert.EqualError(t, err, "1:3: stop")
+
+	// failure - instruction error completes pending calls
+	h.Calls = nil
+	h.Stop = 0
+	e.Words[9] = &Program{Code: []Instr{{User, 8, 0}}, Pos: []Pos{{"", 2, 1}}}
+	err = m.Run(&Program{Code: []Instr{{User, 9, 0}}, Pos: []Pos{{"", 1, 1}}})
+	assert.Equal(t, []string{"before 1:1", "before 2:1", "after 2:1", "after 1:1"}, h.Calls)
+	assert.EqualError(t, err, "2:1: function 8 does not exist")
+}
+
+func TestMachineRunLimits(t *testing.T) {
+	// setup
+	m, e := xMachine()
+	loop := &Program{Code: []Instr{{Loop, 0, 0}}, Pos: []Pos{{"", 1, 1}}}
+	e.Registers[0] = 1
+
+	// failure - instruction limit
+	m.Limits = Limits{Instrs: 100}
+	err := m.Run(loop)
+	assert.Equal(t, 101, m.Count)
+	assert.ErrorIs(t, err, ErrInstrs)
+	assert.EqualError(t, err, "1:1: instruction limit of 100 exceeded")
+
+	// failure - call depth limit
+	m.Limits = Limits{Depth: 10}
+	e.Words[1] = &Program{Code: []Instr{{User, 1, 0}}, Pos: []Pos{{"", 1, 1}}}
+	err = m.Run(e.Words[1])
+	assert.Empty(t, m.Frames)
+	assert.ErrorIs(t, err, ErrDepth)
+	assert.EqualError(t, err, "1:1: call depth limit of 10 exceeded")
+
+	// failure - call depth limit for a redefined builtin
+	e.Words[0] = &Program{Code: []Instr{{Call, 0, 0}}, Pos: []Pos{{"", 1, 1}}}
+	err = m.Run(e.Words[0])
+	assert.ErrorIs(t, err, ErrDepth)
+
+	// failure - context cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	m.Limits = Limits{}
+	m.Context = ctx
+	cancel()
+	err = m.Run(loop)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// failure - context cancelled while running
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	m.Context = ctx
+	err = m.Run(loop)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.EqualError(t, err, "1:1: context deadline exceeded")
+
+	// failure - default call depth limit
+	m, e = xMachine()
+	e.Words[0] = &Program{Code: []Instr{{Call, 0, 0}}, Pos: []Pos{{"", 1, 1}}}
+	err = m.Run(e.Words[0])
+	assert.ErrorIs(t, err, ErrDepth)
+	assert.EqualError(t, err, "1:1: call depth limit of 10000 exceeded")
+}
+
+func TestMachineTrace(t *testing.T) {
+	// setup
+	m, _ := xMachine()
+	m.Frames = []Frame{
+		{&Program{Pos: []Pos{{"", 1, 1}}}, 1},
+		{&Program{Name: "foo", Pos: []Pos{{"", 2, 1}}}, 1},
+		{&Program{Name: "bar"}, 0},
+	}
+
+	// success
+	cs := m.Trace()
+	assert.Equal(t, []Site{{"bar", Pos{"", 2, 1}}, {"foo", Pos{"", 1, 1}}}, cs)
+}
+
+func TestOpString(t *testing.T) {
+	// success - known operation
+	assert.Equal(t, "push", Push.String())
+	assert.Equal(t, "assert", Assert.String())
+	assert.Equal(t, "help", Help.String())
+
+	// success - unknown operation
+	assert.Equal(t, "op255", Op(255).String())
+}
+
+func TestFramePos(t *testing.T) {
+	// setup
+	p := &Program{Pos: []Pos{{"", 1, 2}}}
+
+	// success - current position
+	pos := Frame{p, 1}.Pos()
+	assert.Equal(t, Pos{"", 1, 2}, pos)
+
+	// success - no position
+	pos = Frame{p, 0}.Pos()
+	assert.Equal(t, Pos{}, pos)
+}
+
+func TestProgramEmit(t *testing.T) {
+	// setup
+	p := new(Program)
+
+	// success
+	i := p.Emit(Pos{"", 1, 2}, Push, 1, 2)
+	assert.Equal(t, 0, i)
+	assert.Equal(t, []Instr{{Push, 1, 2}}, p.Code)
+	assert.Equal(t, []Pos{{"", 1, 2}}, p.Pos)
+}
+
+func TestProgramPatch(t *testing.T) {
+	// setup
+	p := &Program{Code: []Instr{{Jump, 0, 0}}}
+
+	// success
+	p.Patch(0, 123)
+	assert.Equal(t, []Instr{{Jump, 123, 0}}, p.Code)
+}